@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"mauk14.library/internal/data"
+	"net/http"
+	"testing"
+)
+
+// TestBooksLinkedByAuthorText checks that books written without authors are
+// linked to the author their author text names, through both the book
+// handlers and the import.
+func TestBooksLinkedByAuthorText(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "heidi@example.com", "books:read", "books:write")
+
+	res := send(t, h, token, http.MethodPost, "/v1/authors", map[string]any{"name": "Frank Herbert"})
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create author: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+	}
+	authorID := int64(res.object(t, "author")["id"].(float64))
+
+	for _, title := range []string{"Dune", "Dune Messiah"} {
+		if res := send(t, h, token, http.MethodPost, "/v1/books", testBook(title)); res.Code != http.StatusCreated {
+			t.Fatalf("create book: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+		}
+	}
+
+	other := testBook("Emma")
+	other["author"] = "Jane Austen"
+	if res := send(t, h, token, http.MethodPost, "/v1/books", other); res.Code != http.StatusCreated {
+		t.Fatalf("create book: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+	}
+
+	res = send(t, h, token, http.MethodPost, "/v1/books/import?format=ndjson", ndjsonBook("Children of Dune", ""))
+	if res.Code != http.StatusOK {
+		t.Fatalf("import: got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+
+	// Updating the text of a linked book leaves its links alone.
+	if res := send(t, h, token, http.MethodPatch, "/v1/books/2", map[string]any{"author": "F. Herbert"}); res.Code != http.StatusOK {
+		t.Fatalf("update book: got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+
+	res = send(t, h, token, http.MethodGet, fmt.Sprintf("/v1/authors/%d/books?sort=id", authorID), nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("list: got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+
+	var titles []string
+	for _, book := range res.body["books"].([]any) {
+		titles = append(titles, book.(map[string]any)["title"].(string))
+	}
+	if want := []string{"Dune", "Dune Messiah", "Children of Dune"}; fmt.Sprint(titles) != fmt.Sprint(want) {
+		t.Errorf("got books %q; want %q", titles, want)
+	}
+}
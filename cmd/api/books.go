@@ -1,20 +1,30 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"mauk14.library/internal/data"
 	"mauk14.library/internal/validator"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 func (app *application) createBookHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string    `json:"title"`
-		Author string    `json:"author"`
-		Year   int32     `json:"year"`
-		Size   data.Size `json:"size"`
-		Genres []string  `json:"genres"`
+		Title     string            `json:"title"`
+		Author    string            `json:"author"`
+		Year      int32             `json:"year"`
+		Size      data.Size         `json:"size"`
+		Genres    []string          `json:"genres"`
+		ISBN10    string            `json:"isbn_10"`
+		ISBN13    string            `json:"isbn_13"`
+		WorkID    int64             `json:"work_id"`
+		Format    string            `json:"format"`
+		Duration  data.Duration     `json:"duration"`
+		Publisher string            `json:"publisher"`
+		Authors   []data.BookAuthor `json:"authors"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -24,21 +34,67 @@ func (app *application) createBookHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	book := &data.Book{
-		Title:  input.Title,
-		Year:   input.Year,
-		Author: input.Author,
-		Size:   input.Size,
-		Genres: input.Genres,
+		Title:     input.Title,
+		Year:      input.Year,
+		Author:    input.Author,
+		Size:      input.Size,
+		Genres:    input.Genres,
+		ISBN10:    input.ISBN10,
+		ISBN13:    input.ISBN13,
+		WorkID:    input.WorkID,
+		Format:    input.Format,
+		Duration:  input.Duration,
+		Publisher: input.Publisher,
 	}
 
+	data.NormalizeISBNs(book)
+
 	v := validator.New()
 
-	if data.ValidateBook(v, book); !v.Valid() {
+	data.ValidateBook(v, book)
+	if input.Authors != nil {
+		data.ValidateBookAuthors(v, input.Authors)
+	}
+
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Books.Insert(book)
+	// A new edition joins an existing work, which is checked in the same
+	// transaction; without a work_id the book starts a work of its own.
+	err = app.saveBook(r.Context(), book, input.Authors, book.WorkID != 0, func(ctx context.Context, m data.Models) error {
+		if book.WorkID != 0 {
+			exists, err := m.Books.WorkExists(ctx, book.WorkID)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return data.ErrUnknownWork
+			}
+		}
+		return m.Books.Insert(ctx, book)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownWork):
+			v.AddError("work_id", "must reference an existing work")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateISBN):
+			v.AddError("isbn_13", "a book with this ISBN already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownAuthor):
+			v.AddError("authors", "must reference existing authors")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.suggestions.clear()
+
+	rendered, err := app.renderBooks(r.Context(), []*data.Book{book}, nil, bookDefaultIncludes)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -46,14 +102,45 @@ func (app *application) createBookHandler(w http.ResponseWriter, r *http.Request
 
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/books/%d", book.ID))
+	headers.Set("ETag", bookETag(book, rendered[0]))
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"book": book}, headers)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"book": rendered[0]}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 
 }
 
+// saveBook runs write, which inserts or updates book, and then replaces the
+// authors of the book. Without authors, a book that has none is linked to
+// the author named by its Author text, if there is one. Both happen in one
+// transaction, so a book is never saved with only part of its authors. When
+// there are no authors to set or link, write runs on its own instead, unless
+// atomic says it needs the transaction for itself.
+func (app *application) saveBook(ctx context.Context, book *data.Book, authors []data.BookAuthor, atomic bool, write func(ctx context.Context, m data.Models) error) error {
+	if authors == nil && !atomic {
+		link, err := app.models.Authors.ExistsByName(ctx, book.Author)
+		if err != nil {
+			return err
+		}
+		if !link {
+			return write(ctx, app.models)
+		}
+	}
+
+	return app.models.WithTx(ctx, func(ctx context.Context, tx data.Models) error {
+		err := write(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if authors == nil {
+			return tx.Authors.LinkByName(ctx, book.ID, book.Author)
+		}
+		return tx.Authors.SetForBook(ctx, book.ID, authors)
+	})
+}
+
 func (app *application) showBookHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil || id < 1 {
@@ -61,7 +148,22 @@ func (app *application) showBookHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	book, err := app.models.Books.Get(id)
+	app.showBook(w, r, id)
+}
+
+// showBook writes the book with the given id, honouring the fields and
+// include parameters and answering If-None-Match, for every route that
+// shows a single book.
+func (app *application) showBook(w http.ResponseWriter, r *http.Request, id int64) {
+	v := validator.New()
+
+	fields, include := app.readBookFields(r.URL.Query(), v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	book, err := app.models.Books.Get(r.Context(), id, fields...)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -72,13 +174,52 @@ func (app *application) showBookHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
+	rendered, err := app.renderBooks(r.Context(), []*data.Book{book}, fields, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	tag := bookETag(book, rendered[0])
+	w.Header().Set("ETag", tag)
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && matchETag(inm, tag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"book": rendered[0]}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 
 	}
 }
 
+func (app *application) showBookByISBNHandler(w http.ResponseWriter, r *http.Request) {
+	isbn := validator.NormalizeISBN(app.readISBNParam(r))
+	if validator.ValidISBN10(isbn) {
+		isbn = validator.ISBN10To13(isbn)
+	}
+
+	if !validator.ValidISBN13(isbn) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	book, err := app.models.Books.GetByISBN(r.Context(), isbn)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.showBook(w, r, book.ID)
+}
+
 func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -86,7 +227,7 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	book, err := app.models.Books.Get(id)
+	book, err := app.models.Books.Get(r.Context(), id)
 
 	if err != nil {
 		switch {
@@ -98,12 +239,23 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if im := r.Header.Get("If-Match"); im != "" && !matchVersion(im, book.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
 	var input struct {
-		Title  *string    `json:"title"`
-		Author *string    `json:"author"`
-		Year   *int32     `json:"year"`
-		Size   *data.Size `json:"size"`
-		Genres []string   `json:"genres"`
+		Title     *string           `json:"title"`
+		Author    *string           `json:"author"`
+		Year      *int32            `json:"year"`
+		Size      *data.Size        `json:"size"`
+		Genres    []string          `json:"genres"`
+		ISBN10    *string           `json:"isbn_10"`
+		ISBN13    *string           `json:"isbn_13"`
+		Format    *string           `json:"format"`
+		Duration  *data.Duration    `json:"duration"`
+		Publisher *string           `json:"publisher"`
+		Authors   []data.BookAuthor `json:"authors"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -129,27 +281,77 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	if input.Genres != nil {
-		book.Genres = book.Genres
+		book.Genres = input.Genres
+	}
+
+	if input.Format != nil {
+		book.Format = *input.Format
+	}
+
+	if input.Duration != nil {
+		book.Duration = *input.Duration
+	}
+
+	if input.Publisher != nil {
+		book.Publisher = *input.Publisher
+	}
+
+	// Changing one form of the ISBN drops the stored counterpart, which
+	// NormalizeISBNs derives again unless both forms are given.
+	if input.ISBN10 != nil || input.ISBN13 != nil {
+		book.ISBN10, book.ISBN13 = "", ""
+		if input.ISBN10 != nil {
+			book.ISBN10 = *input.ISBN10
+		}
+		if input.ISBN13 != nil {
+			book.ISBN13 = *input.ISBN13
+		}
+		data.NormalizeISBNs(book)
 	}
 
 	v := validator.New()
-	if data.ValidateBook(v, book); !v.Valid() {
+
+	data.ValidateBook(v, book)
+	if input.Authors != nil {
+		data.ValidateBookAuthors(v, input.Authors)
+	}
+
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Books.Update(book)
+	err = app.saveBook(r.Context(), book, input.Authors, false, func(ctx context.Context, m data.Models) error {
+		return m.Books.Update(ctx, book)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateISBN):
+			v.AddError("isbn_13", "a book with this ISBN already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownAuthor):
+			v.AddError("authors", "must reference existing authors")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
+	app.suggestions.clear()
+
+	rendered, err := app.renderBooks(r.Context(), []*data.Book{book}, nil, bookDefaultIncludes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", bookETag(book, rendered[0]))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"book": rendered[0]}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -162,55 +364,193 @@ func (app *application) deleteBookHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.models.Books.Delete(id)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
+	if im := r.Header.Get("If-Match"); im != "" {
+		book, err := app.models.Books.Get(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if !matchVersion(im, book.Version) {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+
+		err = app.models.Books.DeleteVersion(r.Context(), id, book.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			case errors.Is(err, data.ErrEditConflict):
+				app.preconditionFailedResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	} else {
+		err = app.models.Books.Delete(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
 		}
-		return
 	}
 
+	app.suggestions.clear()
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "book successfully deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// readBookFilter reads and validates the search criteria shared by the book
+// listing and the catalogue export.
+func (app *application) readBookFilter(qs url.Values, v *validator.Validator) data.BookFilter {
+	var filter data.BookFilter
+
+	filter.Title = app.readString(qs, "title", "")
+	filter.Author = app.readString(qs, "author", "")
+	filter.Match = app.readString(qs, "match", data.MatchContains)
+	filter.Genres = app.readCSV(qs, "genres", []string{})
+
+	// Listing by work shows each work once, through its first edition.
+	by := app.readString(qs, "by", "edition")
+	v.Check(validator.PermittedValue(by, "edition", "work"), "by", "must be edition or work")
+	filter.FirstEditions = by == "work"
+
+	if s := app.readString(qs, "filter", ""); s != "" {
+		expr, err := parseFilter(s)
+		if err != nil {
+			v.AddError("filter", err.Error())
+		}
+		filter.Expr = expr
+	}
+
+	if q := app.readString(qs, "q", ""); q != "" {
+		filter.Search = data.ParseTextSearch(q)
+		data.ValidateTextSearch(v, q, filter.Search)
+	}
+
+	data.ValidateBookFilter(v, filter)
+
+	return filter
+}
+
 func (app *application) listBooksHandler(w http.ResponseWriter, r *http.Request) {
+	app.listBooks(w, r, 0)
+}
+
+// listBooks serves a book listing, limited to the books of an author unless
+// authorID is zero.
+func (app *application) listBooks(w http.ResponseWriter, r *http.Request, authorID int64) {
 	var input struct {
-		Title  string
-		Author string
-		Genres []string
+		data.BookFilter
 		data.Filters
 	}
 
 	v := validator.New()
 	qs := r.URL.Query()
 
-	input.Title = app.readString(qs, "title", "")
-	input.Author = app.readString(qs, "author", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.BookFilter = app.readBookFilter(qs, v)
+	input.AuthorID = authorID
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 
-	input.Filters.Sort = app.readString(qs, "sort", "id")
+	defaultSort := "id"
+	if input.Search != nil {
+		defaultSort = "relevance"
+	}
+
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
 
-	input.Filters.SortSafelist = []string{"id", "title", "year", "size", "-id", "-title", "-year", "-size"}
+	input.Filters.SortSafelist = []string{"id", "title", "author", "year", "size", "created_at", "relevance", "-id", "-title", "-author", "-year", "-size", "-created_at"}
+
+	v.Check(input.Search != nil || !validator.PermittedValue("relevance", strings.Split(input.Filters.Sort, ",")...), "sort", "relevance requires a q search")
+
+	facets := app.readCSV(qs, "facets", []string{})
+	data.ValidateFacets(v, facets)
+
+	var include []string
+	input.Filters.Fields, include = app.readBookFields(qs, v)
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	books, metadata, err := app.models.Books.GetAll(input.Title, input.Author, input.Genres, input.Filters)
+	books, metadata, err := app.models.Books.GetAll(r.Context(), input.BookFilter, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"books": books, "metadata": metadata}, nil)
+
+	rendered, err := app.renderBooks(r.Context(), books, input.Filters.Fields, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"books": rendered, "metadata": metadata}
+
+	if len(facets) != 0 {
+		env["facets"], err = app.models.Books.Facets(r.Context(), input.BookFilter, facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) suggestBooksHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Field  string
+		Prefix string
+		Limit  int
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Field = app.readString(qs, "field", "title")
+	input.Prefix = app.readString(qs, "prefix", "")
+	input.Limit = app.readInt(qs, "limit", 10, v)
+
+	if data.ValidateSuggest(v, input.Field, input.Prefix, input.Limit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	key := fmt.Sprintf("%s:%d:%s", input.Field, input.Limit, strings.ToLower(input.Prefix))
+
+	suggestions, ok := app.suggestions.get(key)
+	if !ok {
+		var err error
+		suggestions, err = app.models.Books.Suggest(r.Context(), input.Field, input.Prefix, input.Limit)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.suggestions.set(key, suggestions)
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
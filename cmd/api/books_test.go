@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mauk14.library/internal/data"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestHealthcheck(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+
+	res := send(t, app.routes(), "", http.MethodGet, "/v1/healthcheck", nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("got status %d; want %d", res.Code, http.StatusOK)
+	}
+	if status := res.body["status"]; status != "available" {
+		t.Errorf("got status %v; want available", status)
+	}
+}
+
+func TestBookLifecycle(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "alice@example.com", "books:read", "books:write")
+
+	res := send(t, h, token, http.MethodPost, "/v1/books", testBook("Dune"))
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+	}
+	id := int64(res.object(t, "book")["id"].(float64))
+
+	url := fmt.Sprintf("/v1/books/%d", id)
+	if location := res.Header().Get("Location"); location != url {
+		t.Errorf("create: got Location %q; want %q", location, url)
+	}
+
+	res = send(t, h, token, http.MethodGet, url, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("show: got status %d; want %d", res.Code, http.StatusOK)
+	}
+	if title := res.object(t, "book")["title"]; title != "Dune" {
+		t.Errorf("show: got title %v; want Dune", title)
+	}
+
+	res = send(t, h, token, http.MethodPatch, url, map[string]any{"title": "Dune Messiah"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("update: got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+
+	res = send(t, h, token, http.MethodGet, "/v1/books?title=messiah", nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("list: got status %d; want %d", res.Code, http.StatusOK)
+	}
+	if total := res.object(t, "metadata")["total_records"]; total != float64(1) {
+		t.Errorf("list: got total_records %v; want 1", total)
+	}
+
+	res = send(t, h, token, http.MethodDelete, url, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("delete: got status %d; want %d", res.Code, http.StatusOK)
+	}
+
+	res = send(t, h, token, http.MethodGet, url, nil)
+	if res.Code != http.StatusNotFound {
+		t.Errorf("show after delete: got status %d; want %d", res.Code, http.StatusNotFound)
+	}
+}
+
+func TestBookPermissions(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "bob@example.com", "books:read")
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		url    string
+		body   any
+		want   int
+	}{
+		{"anonymous list", "", http.MethodGet, "/v1/books", nil, http.StatusUnauthorized},
+		{"reader list", token, http.MethodGet, "/v1/books", nil, http.StatusOK},
+		{"reader create", token, http.MethodPost, "/v1/books", testBook("Dune"), http.StatusForbidden},
+		{"invalid token", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", http.MethodGet, "/v1/books", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(t, h, tt.token, tt.method, tt.url, tt.body)
+			if res.Code != tt.want {
+				t.Errorf("got status %d; want %d", res.Code, tt.want)
+			}
+		})
+	}
+}
+
+// TestCreateBookConcurrentIDs hammers POST /v1/books and checks that every
+// book gets its own ID, with no gaps.
+func TestCreateBookConcurrentIDs(t *testing.T) {
+	const n = 200
+
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "carol@example.com", "books:read", "books:write")
+
+	ids := make([]int64, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			res := send(t, h, token, http.MethodPost, "/v1/books", testBook(fmt.Sprintf("Book %d", i)))
+			if res.Code != http.StatusCreated {
+				t.Errorf("got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+				return
+			}
+			ids[i] = int64(res.body["book"].(map[string]any)["id"].(float64))
+		}(i)
+	}
+	wg.Wait()
+
+	if t.Failed() {
+		return
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for i, id := range ids {
+		if want := int64(i + 1); id != want {
+			t.Fatalf("got IDs %v; want 1 to %d, each once", ids, n)
+		}
+	}
+}
+
+// TestShowBookByISBN checks that a book looked up by ISBN is shown exactly as
+// it is by ID.
+func TestShowBookByISBN(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "grace@example.com", "books:read", "books:write")
+
+	input := testBook("Dune")
+	input["isbn_13"] = "9780441013593"
+
+	res := send(t, h, token, http.MethodPost, "/v1/books", input)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+	}
+	id := int64(res.object(t, "book")["id"].(float64))
+
+	byID := send(t, h, token, http.MethodGet, fmt.Sprintf("/v1/books/%d?fields=title", id), nil)
+	byISBN := send(t, h, token, http.MethodGet, "/v1/books/isbn/0441013597?fields=title", nil)
+	if byISBN.Code != http.StatusOK {
+		t.Fatalf("got status %d; want %d: %s", byISBN.Code, http.StatusOK, byISBN.Body)
+	}
+	if got, want := byISBN.Body.String(), byID.Body.String(); got != want {
+		t.Errorf("got body %s; want %s", got, want)
+	}
+
+	tag := byISBN.Header().Get("ETag")
+	if want := byID.Header().Get("ETag"); tag == "" || tag != want {
+		t.Errorf("got ETag %q; want %q", tag, want)
+	}
+
+	req := newTestRequest(t, token, http.MethodGet, "/v1/books/isbn/9780441013593?fields=title", nil)
+	req.Header.Set("If-None-Match", tag)
+
+	if res := serve(t, h, req); res.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: got status %d; want %d", res.Code, http.StatusNotModified)
+	}
+}
+
+// txCountingDB wraps the memory backend, counting the transactions opened on
+// it.
+type txCountingDB struct {
+	*data.Memory
+	n *int
+}
+
+func (db txCountingDB) WithTx(ctx context.Context, fn func(ctx context.Context, tx data.DB) error) error {
+	*db.n++
+	return db.Memory.WithTx(ctx, fn)
+}
+
+// TestSaveBookTransactions checks that book writes only open a transaction
+// when they have authors to set or link, or a work to check.
+func TestSaveBookTransactions(t *testing.T) {
+	var n int
+	app := newTestApplication(t, txCountingDB{Memory: data.NewMemory(), n: &n})
+	h := app.routes()
+	token := newTestUser(t, app, "judy@example.com", "books:read", "books:write")
+
+	if err := app.models.Authors.Insert(context.Background(), &data.Author{Name: "Jane Austen"}); err != nil {
+		t.Fatal(err)
+	}
+
+	linked := testBook("Emma")
+	linked["author"] = "Jane Austen"
+
+	withAuthors := testBook("Dune Messiah")
+	withAuthors["authors"] = []any{}
+
+	edition := testBook("Dune")
+	edition["work_id"] = 1
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   map[string]any
+		wantTx int
+	}{
+		{"plain create", http.MethodPost, "/v1/books", testBook("Dune"), 0},
+		{"create linked by name", http.MethodPost, "/v1/books", linked, 1},
+		{"create with authors", http.MethodPost, "/v1/books", withAuthors, 1},
+		{"create edition", http.MethodPost, "/v1/books", edition, 1},
+		{"plain update", http.MethodPatch, "/v1/books/1", map[string]any{"year": 1966}, 0},
+		{"update linked by name", http.MethodPatch, "/v1/books/1", map[string]any{"author": "Jane Austen"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n = 0
+
+			res := send(t, h, token, tt.method, tt.url, tt.body)
+			if res.Code != http.StatusCreated && res.Code != http.StatusOK {
+				t.Fatalf("got status %d: %s", res.Code, res.Body)
+			}
+
+			if n != tt.wantTx {
+				t.Errorf("got %d transactions; want %d", n, tt.wantTx)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"mauk14.library/internal/data"
+	"sync"
+	"time"
+)
+
+// suggestionCache keeps recent autocomplete results for a short while, so a
+// search box does not hit the database on every keystroke. A nil
+// *suggestionCache caches nothing.
+type suggestionCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]suggestionEntry
+}
+
+type suggestionEntry struct {
+	suggestions []data.Suggestion
+	expires     time.Time
+}
+
+func newSuggestionCache(ttl time.Duration, maxEntries int) *suggestionCache {
+	if ttl <= 0 || maxEntries <= 0 {
+		return nil
+	}
+
+	return &suggestionCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]suggestionEntry),
+	}
+}
+
+func (c *suggestionCache) get(key string) ([]data.Suggestion, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.suggestions, true
+}
+
+func (c *suggestionCache) set(key string, suggestions []data.Suggestion) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		c.entries = make(map[string]suggestionEntry)
+	}
+
+	c.entries[key] = suggestionEntry{suggestions: suggestions, expires: now.Add(c.ttl)}
+}
+
+// clear drops every entry. It is called whenever books change, so that
+// suggestions never outlive the data they were computed from.
+func (c *suggestionCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.entries = make(map[string]suggestionEntry)
+	c.mu.Unlock()
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mauk14.library/internal/data"
+	"mauk14.library/internal/validator"
+	"net/http"
+	"strings"
+)
+
+// includeBookAvailability embeds the number of copies of each book by status.
+func includeBookAvailability(app *application, ctx context.Context, books []*data.Book) (map[int64]any, error) {
+	ids := make([]int64, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+	}
+
+	counts, err := app.models.Copies.CountForBooks(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	related := make(map[int64]any, len(books))
+	for _, book := range books {
+		related[book.ID] = counts[book.ID]
+	}
+
+	return related, nil
+}
+
+// readBookCopy loads the copy named by the path of
+// /v1/books/:id/copies/:copy_id, returning ErrRecordNotFound unless it is a
+// copy of that book.
+func (app *application) readBookCopy(r *http.Request) (*data.Copy, error) {
+	bookID, err := app.readIDParam(r)
+	if err != nil {
+		return nil, data.ErrRecordNotFound
+	}
+
+	id, err := app.readCopyIDParam(r)
+	if err != nil {
+		return nil, data.ErrRecordNotFound
+	}
+
+	c, err := app.models.Copies.Get(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.BookID != bookID {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return c, nil
+}
+
+func (app *application) createCopyHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Barcode   string `json:"barcode"`
+		Branch    string `json:"branch"`
+		Shelf     string `json:"shelf"`
+		Condition string `json:"condition"`
+		Status    string `json:"status"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	c := &data.Copy{
+		BookID:    bookID,
+		Barcode:   strings.TrimSpace(input.Barcode),
+		Branch:    input.Branch,
+		Shelf:     input.Shelf,
+		Condition: input.Condition,
+		Status:    input.Status,
+	}
+
+	// New copies are usually in good order and on the shelf.
+	if c.Condition == "" {
+		c.Condition = "good"
+	}
+	if c.Status == "" {
+		c.Status = data.CopyAvailable
+	}
+
+	v := validator.New()
+
+	if data.ValidateCopy(v, c); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Copies.Insert(r.Context(), c)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateBarcode):
+			v.AddError("barcode", "a copy with this barcode already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/books/%d/copies/%d", bookID, c.ID))
+	headers.Set("ETag", etag(c.Version))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"copy": c}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listCopiesHandler lists the copies of a book, optionally only those with a
+// status or at a branch, along with the availability of the book as a whole.
+func (app *application) listCopiesHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.CopyFilter
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.BookID = bookID
+	input.Status = app.readString(qs, "status", "")
+	input.Branch = app.readString(qs, "branch", "")
+	data.ValidateCopyFilter(v, input.CopyFilter)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "barcode", "branch", "status", "-id", "-barcode", "-branch", "-status"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Books.Get(r.Context(), bookID, "id")
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	copies, metadata, err := app.models.Copies.GetAll(r.Context(), input.CopyFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	counts, err := app.models.Copies.CountForBooks(r.Context(), []int64{bookID})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"copies": copies, "availability": counts[bookID], "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showCopyHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := app.readBookCopy(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	tag := etag(c.Version)
+	w.Header().Set("ETag", tag)
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && matchETag(inm, tag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"copy": c}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCopyHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := app.readBookCopy(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if im := r.Header.Get("If-Match"); im != "" && !matchETag(im, etag(c.Version), false) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Barcode   *string `json:"barcode"`
+		Branch    *string `json:"branch"`
+		Shelf     *string `json:"shelf"`
+		Condition *string `json:"condition"`
+		Status    *string `json:"status"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Barcode != nil {
+		c.Barcode = strings.TrimSpace(*input.Barcode)
+	}
+
+	if input.Branch != nil {
+		c.Branch = *input.Branch
+	}
+
+	if input.Shelf != nil {
+		c.Shelf = *input.Shelf
+	}
+
+	if input.Condition != nil {
+		c.Condition = *input.Condition
+	}
+
+	if input.Status != nil {
+		c.Status = *input.Status
+	}
+
+	v := validator.New()
+	if data.ValidateCopy(v, c); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Copies.Update(r.Context(), c)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateBarcode):
+			v.AddError("barcode", "a copy with this barcode already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(c.Version))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"copy": c}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCopyHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := app.readBookCopy(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag(c.Version), false) {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+
+		err = app.models.Copies.DeleteVersion(r.Context(), c.ID, c.Version)
+	} else {
+		err = app.models.Copies.Delete(r.Context(), c.ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "copy successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"mauk14.library/internal/data"
+	"net/http"
+	"testing"
+)
+
+func TestBookAvailability(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "ivan@example.com", "books:read", "books:write", "copies:write")
+
+	res := send(t, h, token, http.MethodPost, "/v1/books", testBook("Dune"))
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create book: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+	}
+	url := fmt.Sprintf("/v1/books/%d", int64(res.object(t, "book")["id"].(float64)))
+	if _, ok := res.object(t, "book")["availability"]; !ok {
+		t.Errorf("got a created book without availability")
+	}
+
+	// availability checks the book's availability as shown by default, and
+	// returns the book's ETag.
+	availability := func(want map[string]any) string {
+		t.Helper()
+
+		res := send(t, h, token, http.MethodGet, url, nil)
+		if res.Code != http.StatusOK {
+			t.Fatalf("show: got status %d; want %d", res.Code, http.StatusOK)
+		}
+		got := res.object(t, "book")["availability"]
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got availability %v; want %v", got, want)
+		}
+		return res.Header().Get("ETag")
+	}
+
+	before := availability(map[string]any{"total": 0, "available": 0, "on_loan": 0, "lost": 0, "in_repair": 0})
+
+	res = send(t, h, token, http.MethodPost, url+"/copies", map[string]any{"barcode": "DUNE-1", "branch": "Main"})
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create copy: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+	}
+	copyURL := res.Header().Get("Location")
+
+	created := availability(map[string]any{"total": 1, "available": 1, "on_loan": 0, "lost": 0, "in_repair": 0})
+	if created == before {
+		t.Errorf("creating a copy kept the book's ETag %s", before)
+	}
+
+	// A cached representation without the copy is stale.
+	req := newTestRequest(t, token, http.MethodGet, url, nil)
+	req.Header.Set("If-None-Match", before)
+	if res := serve(t, h, req); res.Code != http.StatusOK {
+		t.Errorf("If-None-Match: got status %d; want %d", res.Code, http.StatusOK)
+	}
+
+	res = send(t, h, token, http.MethodPatch, copyURL, map[string]any{"status": "on_loan"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("update copy: got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+
+	loaned := availability(map[string]any{"total": 1, "available": 0, "on_loan": 1, "lost": 0, "in_repair": 0})
+	if loaned == created {
+		t.Errorf("lending a copy kept the book's ETag %s", created)
+	}
+
+	// The copies left the book's version alone, so a write conditional on
+	// the tag from before them still applies.
+	req = newTestRequest(t, token, http.MethodPatch, url, map[string]any{"year": 1966})
+	req.Header.Set("If-Match", before)
+	res = serve(t, h, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("update book: got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+	if _, ok := res.object(t, "book")["availability"]; !ok {
+		t.Errorf("got an updated book without availability")
+	}
+	if tag := res.Header().Get("ETag"); tag != availability(map[string]any{"total": 1, "available": 0, "on_loan": 1, "lost": 0, "in_repair": 0}) {
+		t.Errorf("update: got ETag %s; want the one shown", tag)
+	}
+
+	res = send(t, h, token, http.MethodGet, url+"?fields=title", nil)
+	if _, ok := res.object(t, "book")["availability"]; ok {
+		t.Errorf("got availability with fields=title; want only the title")
+	}
+
+	res = send(t, h, token, http.MethodGet, "/v1/books", nil)
+	books := res.body["books"].([]any)
+	if _, ok := books[0].(map[string]any)["availability"]; !ok {
+		t.Errorf("got a listed book without availability")
+	}
+}
+
+func TestCopyConditionalRequests(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "judy@example.com", "books:read", "books:write", "copies:write")
+
+	res := send(t, h, token, http.MethodPost, "/v1/books", testBook("Dune"))
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create book: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+	}
+
+	res = send(t, h, token, http.MethodPost, fmt.Sprintf("/v1/books/%d/copies", int64(res.object(t, "book")["id"].(float64))), map[string]any{"barcode": "DUNE-1", "branch": "Main"})
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create copy: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+	}
+	url := res.Header().Get("Location")
+
+	created := res.Header().Get("ETag")
+	if created == "" {
+		t.Fatal("create copy: got no ETag")
+	}
+
+	// conditional serves a request for the copy with one conditional header.
+	conditional := func(method, header, tag string, body any) testResponse {
+		t.Helper()
+
+		req := newTestRequest(t, token, method, url, body)
+		req.Header.Set(header, tag)
+		return serve(t, h, req)
+	}
+
+	if res := conditional(http.MethodGet, "If-None-Match", created, nil); res.Code != http.StatusNotModified {
+		t.Errorf("show: got status %d; want %d", res.Code, http.StatusNotModified)
+	}
+
+	res = conditional(http.MethodPatch, "If-Match", created, map[string]any{"shelf": "SF-HER"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("update: got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+	updated := res.Header().Get("ETag")
+	if updated == "" || updated == created {
+		t.Errorf("update: got ETag %q; want a new one", updated)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		tag    string
+		body   any
+		want   int
+	}{
+		{"show changed", http.MethodGet, "If-None-Match", created, nil, http.StatusOK},
+		{"show unchanged", http.MethodGet, "If-None-Match", updated, nil, http.StatusNotModified},
+		{"update stale", http.MethodPatch, "If-Match", created, map[string]any{"shelf": "SF"}, http.StatusPreconditionFailed},
+		{"delete stale", http.MethodDelete, "If-Match", created, nil, http.StatusPreconditionFailed},
+		{"delete current", http.MethodDelete, "If-Match", updated, nil, http.StatusOK},
+		{"delete again", http.MethodDelete, "If-Match", updated, nil, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := conditional(tt.method, tt.header, tt.tag, tt.body); res.Code != tt.want {
+				t.Errorf("got status %d; want %d", res.Code, tt.want)
+			}
+		})
+	}
+}
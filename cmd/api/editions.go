@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"mauk14.library/internal/data"
+)
+
+// includeBookEditions embeds every edition of each book's work, the book
+// itself included, in order of ID.
+func includeBookEditions(app *application, ctx context.Context, books []*data.Book) (map[int64]any, error) {
+	workIDs := []int64{}
+	seen := make(map[int64]bool, len(books))
+	for _, book := range books {
+		if !seen[book.WorkID] {
+			seen[book.WorkID] = true
+			workIDs = append(workIDs, book.WorkID)
+		}
+	}
+
+	editions := make(map[int64][]*data.Book, len(workIDs))
+
+	filters := data.Filters{Sort: "id", SortSafelist: []string{"id"}}
+
+	err := app.models.Books.Walk(ctx, data.BookFilter{WorkIDs: workIDs}, filters, func(book *data.Book) error {
+		editions[book.WorkID] = append(editions[book.WorkID], book)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	related := make(map[int64]any, len(books))
+	for _, book := range books {
+		related[book.ID] = editions[book.WorkID]
+	}
+
+	return related, nil
+}
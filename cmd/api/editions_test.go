@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"mauk14.library/internal/data"
+	"net/http"
+	"testing"
+)
+
+// bookIDs returns the ids of the books in a listing or an include.
+func bookIDs(books any) []int64 {
+	ids := []int64{}
+	for _, book := range books.([]any) {
+		ids = append(ids, int64(book.(map[string]any)["id"].(float64)))
+	}
+	return ids
+}
+
+func TestEditions(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "nia@example.com", "books:read", "books:write")
+
+	hardcover := testBook("Dune")
+	hardcover["format"] = "hardcover"
+
+	paperback := testBook("Dune")
+	paperback["format"] = "paperback"
+	paperback["work_id"] = 1
+
+	for _, book := range []map[string]any{hardcover, paperback, testBook("Dune Messiah")} {
+		if res := send(t, h, token, http.MethodPost, "/v1/books", book); res.Code != http.StatusCreated {
+			t.Fatalf("create: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+		}
+	}
+
+	orphan := testBook("Dune")
+	orphan["work_id"] = 99
+	res := send(t, h, token, http.MethodPost, "/v1/books", orphan)
+	if got := res.object(t, "error")["work_id"]; res.Code != http.StatusUnprocessableEntity || got != "must reference an existing work" {
+		t.Errorf("unknown work: got status %d and error %v", res.Code, got)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		key  string
+		want []int64
+	}{
+		{"by edition", "/v1/books?sort=id", "books", []int64{1, 2, 3}},
+		{"by work", "/v1/books?sort=id&by=work", "books", []int64{1, 3}},
+		{"filtered by work", "/v1/books?sort=-id&by=work&title=dune", "books", []int64{3, 1}},
+		{"editions of a later edition", "/v1/books/2?include=editions", "book", []int64{1, 2}},
+		{"editions of a single edition", "/v1/books/3?include=editions", "book", []int64{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(t, h, token, http.MethodGet, tt.url, nil)
+			if res.Code != http.StatusOK {
+				t.Fatalf("got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+			}
+
+			books := res.body["books"]
+			if tt.key == "book" {
+				books = res.object(t, "book")["editions"]
+			}
+			if got := bookIDs(books); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got books %v; want %v", got, tt.want)
+			}
+		})
+	}
+
+	if res := send(t, h, token, http.MethodGet, "/v1/books?by=author", nil); res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("by=author: got status %d; want %d", res.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+// TestDeleteFirstEdition checks that the remaining editions of a work whose
+// first edition is deleted move to the next one, and that clients holding
+// them see the change.
+func TestDeleteFirstEdition(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "omar@example.com", "books:read", "books:write")
+
+	for _, workID := range []int64{0, 1, 1} {
+		book := testBook("Dune")
+		if workID != 0 {
+			book["work_id"] = workID
+		}
+		if res := send(t, h, token, http.MethodPost, "/v1/books", book); res.Code != http.StatusCreated {
+			t.Fatalf("create: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+		}
+	}
+
+	before := send(t, h, token, http.MethodGet, "/v1/books/3", nil)
+
+	if res := send(t, h, token, http.MethodDelete, "/v1/books/1", nil); res.Code != http.StatusOK {
+		t.Fatalf("delete: got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+
+	req := newTestRequest(t, token, http.MethodGet, "/v1/books/3", nil)
+	req.Header.Set("If-None-Match", before.Header().Get("ETag"))
+
+	res := serve(t, h, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("If-None-Match: got status %d; want %d", res.Code, http.StatusOK)
+	}
+	if got := res.object(t, "book")["work_id"]; got != float64(2) {
+		t.Errorf("got work_id %v; want 2", got)
+	}
+
+	res = send(t, h, token, http.MethodGet, "/v1/books?by=work", nil)
+	if got := bookIDs(res.body["books"]); fmt.Sprint(got) != "[2]" {
+		t.Errorf("got works %v; want [2]", got)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"mauk14.library/internal/data"
 	"net/http"
 )
 
@@ -23,10 +25,38 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 	}
 }
 
+// serverErrorResponse reports an unexpected error. Database errors that a
+// client can sensibly retry are reported with their own status instead of
+// a plain 500, whichever backend produced them, and are logged as info
+// since they are expected under load rather than a fault in the server.
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logError(r, err)
-	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	switch {
+	case errors.Is(err, data.ErrConflict):
+		app.logRetryable(r, err)
+		app.editConflictResponse(w, r)
+	case errors.Is(err, data.ErrTimeout):
+		app.logRetryable(r, err)
+		app.serviceUnavailableResponse(w, r, "the database took too long to respond, please try again")
+	case errors.Is(err, data.ErrUnavailable):
+		app.logRetryable(r, err)
+		app.serviceUnavailableResponse(w, r, "the database is temporarily unavailable, please try again later")
+	default:
+		app.logError(r, err)
+		message := "the server encountered a problem and could not process your request"
+		app.errorResponse(w, r, http.StatusInternalServerError, message)
+	}
+}
+
+func (app *application) logRetryable(r *http.Request, err error) {
+	app.logger.PrintInfo(err.Error(), map[string]string{
+		"request_method": r.Method,
+		"request_url":    r.URL.String(),
+	})
+}
+
+func (app *application) serviceUnavailableResponse(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("Retry-After", "5")
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
@@ -52,6 +82,11 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has been modified since you last retrieved it"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mauk14.library/internal/data"
+	"mauk14.library/internal/jsonlog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantLevel  string
+	}{
+		{"unexpected", errors.New("boom"), http.StatusInternalServerError, "ERROR"},
+		{"conflict", fmt.Errorf("insert book: %w", data.ErrConflict), http.StatusConflict, "INFO"},
+		{"timeout", fmt.Errorf("list books: %w", data.ErrTimeout), http.StatusServiceUnavailable, "INFO"},
+		{"unavailable", fmt.Errorf("get book: %w", data.ErrUnavailable), http.StatusServiceUnavailable, "INFO"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log bytes.Buffer
+			app := &application{logger: jsonlog.New(&log, jsonlog.LevelInfo)}
+
+			w := httptest.NewRecorder()
+			app.serverErrorResponse(w, httptest.NewRequest(http.MethodGet, "/v1/books", nil), tt.err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d; want %d", w.Code, tt.wantStatus)
+			}
+
+			var entry struct{ Level, Message string }
+			if err := json.Unmarshal(log.Bytes(), &entry); err != nil {
+				t.Fatalf("decode log entry %q: %v", log.String(), err)
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.err.Error() {
+				t.Errorf("got log entry %+v; want level %s and message %q", entry, tt.wantLevel, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mauk14.library/internal/data"
+	"mauk14.library/internal/validator"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// exportBook is the form of a book in an export. Unlike the API responses it
+// includes the size, which is written the way Size.MarshalJSON renders it.
+// Audiobooks have a duration instead.
+type exportBook struct {
+	ID        int64         `json:"id"`
+	Title     string        `json:"title"`
+	Author    string        `json:"author"`
+	Year      int32         `json:"year,omitempty"`
+	Size      data.Size     `json:"size,omitempty"`
+	Genres    []string      `json:"genres,omitempty"`
+	ISBN10    string        `json:"isbn_10,omitempty"`
+	ISBN13    string        `json:"isbn_13,omitempty"`
+	WorkID    int64         `json:"work_id"`
+	Format    string        `json:"format,omitempty"`
+	Duration  data.Duration `json:"duration,omitempty"`
+	Publisher string        `json:"publisher,omitempty"`
+	Version   string        `json:"version"`
+}
+
+func newExportBook(book *data.Book) exportBook {
+	return exportBook{
+		ID:        book.ID,
+		Title:     book.Title,
+		Author:    book.Author,
+		Year:      book.Year,
+		Size:      book.Size,
+		Genres:    book.Genres,
+		ISBN10:    book.ISBN10,
+		ISBN13:    book.ISBN13,
+		WorkID:    book.WorkID,
+		Format:    book.Format,
+		Duration:  book.Duration,
+		Publisher: book.Publisher,
+		Version:   book.Version.String(),
+	}
+}
+
+// exportCSVColumns are the columns of a CSV export. Genres are joined with
+// semicolons, as the import expects them, and a missing size or duration is
+// left empty.
+var exportCSVColumns = []string{"id", "title", "author", "year", "size", "genres", "isbn_10", "isbn_13", "work_id", "format", "duration", "publisher", "version"}
+
+func (b exportBook) csvRecord() []string {
+	var size, duration string
+	if b.Size != 0 {
+		size = b.Size.String()
+	}
+	if b.Duration != 0 {
+		duration = b.Duration.String()
+	}
+
+	return []string{
+		strconv.FormatInt(b.ID, 10),
+		b.Title,
+		b.Author,
+		strconv.FormatInt(int64(b.Year), 10),
+		size,
+		strings.Join(b.Genres, ";"),
+		b.ISBN10,
+		b.ISBN13,
+		strconv.FormatInt(b.WorkID, 10),
+		b.Format,
+		duration,
+		b.Publisher,
+		b.Version,
+	}
+}
+
+// countingWriter counts the bytes that reach w, telling the export whether
+// an error can still be reported with a proper response.
+type countingWriter struct {
+	w       io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+func (app *application) exportBooksHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.BookFilter
+		data.Filters
+		Format string
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.BookFilter = app.readBookFilter(qs, v)
+
+	input.Format = app.readString(qs, "format", "json")
+	v.Check(validator.PermittedValue(input.Format, "csv", "ndjson", "json"), "format", "must be csv, ndjson or json")
+
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "title", "author", "year", "size", "created_at", "-id", "-title", "-author", "-year", "-size", "-created_at"}
+
+	if data.ValidateSort(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var contentType string
+	switch input.Format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	case "json":
+		contentType = "application/json"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="books.`+input.Format+`"`)
+
+	out := &countingWriter{w: w}
+	buf := bufio.NewWriter(out)
+
+	var write func(book exportBook) error
+	var end func() error
+
+	switch input.Format {
+	case "csv":
+		cw := csv.NewWriter(buf)
+		if err := cw.Write(exportCSVColumns); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		write = func(book exportBook) error {
+			return cw.Write(book.csvRecord())
+		}
+		end = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case "ndjson":
+		enc := json.NewEncoder(buf)
+		write = func(book exportBook) error {
+			return enc.Encode(book)
+		}
+		end = func() error {
+			return nil
+		}
+	case "json":
+		buf.WriteString(`{"books":[`)
+		first := true
+		write = func(book exportBook) error {
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+
+			js, err := json.Marshal(book)
+			if err != nil {
+				return err
+			}
+			_, err = buf.Write(js)
+			return err
+		}
+		end = func() error {
+			_, err := buf.WriteString("]}\n")
+			return err
+		}
+	}
+
+	err := app.models.Books.Walk(r.Context(), input.BookFilter, input.Filters, func(book *data.Book) error {
+		return write(newExportBook(book))
+	})
+	if err == nil {
+		err = end()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+
+	if err != nil {
+		// Once part of the export is sent the status can no longer change,
+		// so the error is only logged and the client sees a truncated body.
+		if out.written == 0 {
+			w.Header().Del("Content-Disposition")
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.logError(r, err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/google/uuid"
+	"mauk14.library/internal/data"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportCSVRecord(t *testing.T) {
+	version := uuid.MustParse("6f1c2b9e-3d4a-4c5b-8e7f-9a0b1c2d3e4f")
+
+	tests := []struct {
+		name string
+		book *data.Book
+		want []string
+	}{
+		{
+			name: "paperback",
+			book: &data.Book{ID: 1, Title: "Dune", Author: "Frank Herbert", Year: 1965, Size: 412, Genres: []string{"science fiction", "classic"}, ISBN13: "9780441013593", WorkID: 1, Format: "paperback", Version: version},
+			want: []string{"1", "Dune", "Frank Herbert", "1965", "412 pages", "science fiction;classic", "", "9780441013593", "1", "paperback", "", "", version.String()},
+		},
+		{
+			name: "audiobook",
+			book: &data.Book{ID: 2, Title: "Dune", Author: "Frank Herbert", Year: 2006, Genres: []string{"science fiction"}, WorkID: 1, Format: "audiobook", Duration: 1263, Publisher: "Macmillan Audio", Version: version},
+			want: []string{"2", "Dune", "Frank Herbert", "2006", "", "science fiction", "", "", "1", "audiobook", "1263 minutes", "Macmillan Audio", version.String()},
+		},
+		{
+			name: "quoted text",
+			book: &data.Book{ID: 3, Title: `Dune, "Deluxe"`, Author: "Frank Herbert", Year: 1965, Size: 1, WorkID: 3, Version: version},
+			want: []string{"3", `Dune, "Deluxe"`, "Frank Herbert", "1965", "1 pages", "", "", "", "3", "", "", "", version.String()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newExportBook(tt.book).csvRecord()
+			if len(got) != len(exportCSVColumns) {
+				t.Fatalf("got %d fields; want one per column, %d", len(got), len(exportCSVColumns))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got record %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportBooks(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "mo@example.com", "books:read", "books:write")
+
+	for _, title := range []string{"Dune", "Dune Messiah", "Children of Dune"} {
+		if res := send(t, h, token, http.MethodPost, "/v1/books", testBook(title)); res.Code != http.StatusCreated {
+			t.Fatalf("create: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+		}
+	}
+
+	// Each parser returns the title and size of every exported book.
+	tests := []struct {
+		format      string
+		contentType string
+		parse       func(t *testing.T, body string) [][2]string
+	}{
+		{
+			format:      "csv",
+			contentType: "text/csv; charset=utf-8",
+			parse: func(t *testing.T, body string) [][2]string {
+				records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(records[0], exportCSVColumns) {
+					t.Errorf("got header %q; want %q", records[0], exportCSVColumns)
+				}
+				var books [][2]string
+				for _, record := range records[1:] {
+					books = append(books, [2]string{record[1], record[4]})
+				}
+				return books
+			},
+		},
+		{
+			format:      "ndjson",
+			contentType: "application/x-ndjson",
+			parse: func(t *testing.T, body string) [][2]string {
+				var books [][2]string
+				scanner := bufio.NewScanner(strings.NewReader(body))
+				for scanner.Scan() {
+					var book struct{ Title, Size string }
+					if err := json.Unmarshal(scanner.Bytes(), &book); err != nil {
+						t.Fatal(err)
+					}
+					books = append(books, [2]string{book.Title, book.Size})
+				}
+				return books
+			},
+		},
+		{
+			format:      "json",
+			contentType: "application/json",
+			parse: func(t *testing.T, body string) [][2]string {
+				var export struct {
+					Books []struct{ Title, Size string }
+				}
+				if err := json.Unmarshal([]byte(body), &export); err != nil {
+					t.Fatal(err)
+				}
+				var books [][2]string
+				for _, book := range export.Books {
+					books = append(books, [2]string{book.Title, book.Size})
+				}
+				return books
+			},
+		},
+	}
+
+	want := [][2]string{{"Children of Dune", "412 pages"}, {"Dune", "412 pages"}, {"Dune Messiah", "412 pages"}}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			res := send(t, h, token, http.MethodGet, "/v1/books/export?sort=title&format="+tt.format, nil)
+			if res.Code != http.StatusOK {
+				t.Fatalf("got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+			}
+
+			if got := res.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("got Content-Type %q; want %q", got, tt.contentType)
+			}
+
+			if got := tt.parse(t, res.Body.String()); !reflect.DeepEqual(got, want) {
+				t.Errorf("got books %q; want %q", got, want)
+			}
+		})
+	}
+
+	res := send(t, h, token, http.MethodGet, "/v1/books/export?format=xml", nil)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("format=xml: got status %d; want %d", res.Code, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mauk14.library/internal/data"
+	"mauk14.library/internal/validator"
+	"net/url"
+	"strconv"
+)
+
+// bookInclude loads a resource related to books, returning the value to embed
+// in each book keyed by the book id.
+type bookInclude func(app *application, ctx context.Context, books []*data.Book) (map[int64]any, error)
+
+// bookIncludes lists the relations clients can embed in book responses with
+// include=. Each relation is added here as its resource is introduced.
+var bookIncludes = map[string]bookInclude{
+	"authors":      includeBookAuthors,
+	"editions":     includeBookEditions,
+	"availability": includeBookAvailability,
+}
+
+// bookDefaultIncludes are embedded in every book response that does not
+// select fields, without being asked for.
+var bookDefaultIncludes = []string{"availability"}
+
+// readBookFields reads the fields and include parameters of a book response.
+// Unless fields are selected, include also holds the default includes.
+func (app *application) readBookFields(qs url.Values, v *validator.Validator) (fields []string, include []string) {
+	fields = app.readCSV(qs, "fields", nil)
+	data.ValidateFields(v, fields, data.BookFieldSafelist)
+
+	include = app.readCSV(qs, "include", nil)
+	for _, name := range include {
+		_, ok := bookIncludes[name]
+		v.Check(ok, "include", "invalid include value")
+	}
+	v.Check(validator.Unique(include), "include", "must not contain duplicate values")
+
+	if len(fields) == 0 {
+		for _, name := range bookDefaultIncludes {
+			if !validator.PermittedValue(name, include...) {
+				include = append(include, name)
+			}
+		}
+	}
+
+	return fields, include
+}
+
+// renderBooks prepares books for writeJSON. Without fields or include the
+// books are returned unchanged; otherwise each book becomes an object holding
+// only the selected fields plus the included relations.
+func (app *application) renderBooks(ctx context.Context, books []*data.Book, fields []string, include []string) ([]any, error) {
+	rendered := make([]any, len(books))
+
+	if len(fields) == 0 && len(include) == 0 {
+		for i, book := range books {
+			rendered[i] = book
+		}
+		return rendered, nil
+	}
+
+	for i, book := range books {
+		js, err := json.Marshal(book)
+		if err != nil {
+			return nil, err
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(js, &object); err != nil {
+			return nil, err
+		}
+
+		if len(fields) != 0 {
+			selected := make(map[string]json.RawMessage, len(fields))
+			for _, field := range fields {
+				if value, ok := object[field]; ok {
+					selected[field] = value
+				}
+			}
+			object = selected
+		}
+
+		m := make(map[string]any, len(object)+len(include))
+		for key, value := range object {
+			m[key] = value
+		}
+		rendered[i] = m
+	}
+
+	for _, name := range include {
+		related, err := bookIncludes[name](app, ctx, books)
+		if err != nil {
+			return nil, err
+		}
+		for i, book := range books {
+			rendered[i].(map[string]any)[name] = related[book.ID]
+		}
+	}
+
+	return rendered, nil
+}
+
+// bookETag returns the entity tag of a rendered book. Copies change the
+// availability of a book but not its version, so when the availability is
+// rendered its counts follow the version in the tag. matchVersion still
+// matches such a tag against the version alone.
+func bookETag(book *data.Book, rendered any) string {
+	tag := book.Version.String()
+
+	if m, ok := rendered.(map[string]any); ok {
+		if a, ok := m["availability"].(data.Availability); ok {
+			tag += fmt.Sprintf("+%d.%d.%d.%d", a.Available, a.OnLoan, a.Lost, a.InRepair)
+		}
+	}
+
+	return strconv.Quote(tag)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"mauk14.library/internal/data"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestBookFields(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "kim@example.com", "books:read", "books:write")
+
+	for _, title := range []string{"Dune", "Dune Messiah"} {
+		if res := send(t, h, token, http.MethodPost, "/v1/books", testBook(title)); res.Code != http.StatusCreated {
+			t.Fatalf("create: got status %d; want %d: %s", res.Code, http.StatusCreated, res.Body)
+		}
+	}
+
+	full := []string{"id", "title", "author", "year", "genres", "work_id", "version", "availability"}
+
+	tests := []struct {
+		name     string
+		url      string
+		wantKeys []string
+	}{
+		{"default", "/v1/books/1", full},
+		{"fields", "/v1/books/1?fields=id,title", []string{"id", "title"}},
+		{"fields without defaults", "/v1/books/1?fields=title,version", []string{"title", "version"}},
+		{"include with fields", "/v1/books/1?fields=title&include=authors", []string{"title", "authors"}},
+		{"include with defaults", "/v1/books/1?include=editions", append([]string{"editions"}, full...)},
+		{"default asked for", "/v1/books/1?fields=title&include=availability", []string{"title", "availability"}},
+		{"listing", "/v1/books?fields=title&sort=-year", []string{"title"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(t, h, token, http.MethodGet, tt.url, nil)
+			if res.Code != http.StatusOK {
+				t.Fatalf("got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+			}
+
+			book, ok := res.body["book"].(map[string]any)
+			if !ok {
+				book = res.body["books"].([]any)[0].(map[string]any)
+			}
+
+			var keys []string
+			for key := range book {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			want := append([]string(nil), tt.wantKeys...)
+			sort.Strings(want)
+
+			if !reflect.DeepEqual(keys, want) {
+				t.Errorf("got keys %v; want %v", keys, want)
+			}
+		})
+	}
+}
+
+func TestBookFieldsValidation(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	h := app.routes()
+	token := newTestUser(t, app, "lee@example.com", "books:read")
+
+	tests := []struct {
+		name string
+		url  string
+		key  string
+		want string
+	}{
+		{"unknown field", "/v1/books?fields=title,bogus", "fields", "invalid fields value"},
+		{"duplicate field", "/v1/books?fields=title,title", "fields", "must not contain duplicate values"},
+		{"unknown include", "/v1/books?include=reviews", "include", "invalid include value"},
+		{"duplicate include", "/v1/books?include=authors,authors", "include", "must not contain duplicate values"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(t, h, token, http.MethodGet, tt.url, nil)
+			if res.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("got status %d; want %d: %s", res.Code, http.StatusUnprocessableEntity, res.Body)
+			}
+
+			if got := res.object(t, "error")[tt.key]; got != tt.want {
+				t.Errorf("got %s error %v; want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mauk14.library/internal/data"
+	"mauk14.library/internal/validator"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The filter query parameter of listBooksHandler takes an expression such as
+//
+//	year>=1990 and (genres=any:fantasy,horror or not author="Stephen King")
+//
+// Conditions compare a field with a value, and are combined with and, or,
+// not and parentheses; and binds tighter than or. Values containing spaces,
+// parentheses or operator characters must be double-quoted. Genres take a
+// comma-separated list, prefixed with any: or all: (the default).
+
+const (
+	maxFilterLength     = 1000
+	maxFilterConditions = 20
+	maxFilterDepth      = 10
+)
+
+type filterToken struct {
+	kind  string // "(", ")", "op", "word" or "string"
+	value string
+	pos   int
+}
+
+func tokenizeFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken{kind: string(c), value: string(c), pos: i})
+			i++
+		case strings.IndexByte("=!<>", c) >= 0:
+			j := i + 1
+			for j < len(s) && strings.IndexByte("=!<>", s[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: "op", value: s[i:j], pos: i})
+			i = j
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j == len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, filterToken{kind: "string", value: b.String(), pos: i})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t()=!<>\"", s[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: "word", value: s[i:j], pos: i})
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens     []filterToken
+	pos        int
+	conditions int
+}
+
+// parseFilter parses a filter expression into the AST the data stores
+// compile. The returned error is suitable to show to the client.
+func parseFilter(s string) (data.FilterExpr, error) {
+	if len(s) > maxFilterLength {
+		return nil, fmt.Errorf("must not be more than %d bytes long", maxFilterLength)
+	}
+
+	tokens, err := tokenizeFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("must not be empty")
+	}
+
+	p := &filterParser{tokens: tokens}
+
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.value, t.pos+1)
+	}
+
+	return expr, nil
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	t, ok := p.peek()
+	if !ok {
+		return t, errors.New("unexpected end of expression")
+	}
+	p.pos++
+	return t, nil
+}
+
+func (p *filterParser) keyword(word string) bool {
+	t, ok := p.peek()
+	if ok && t.kind == "word" && strings.EqualFold(t.value, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr(depth int) (data.FilterExpr, error) {
+	if depth > maxFilterDepth {
+		return nil, fmt.Errorf("must not nest more than %d levels deep", maxFilterDepth)
+	}
+
+	expr, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []data.FilterExpr{expr}
+	for p.keyword("or") {
+		expr, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return data.FilterOr{Exprs: exprs}, nil
+}
+
+func (p *filterParser) parseAnd(depth int) (data.FilterExpr, error) {
+	expr, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []data.FilterExpr{expr}
+	for p.keyword("and") {
+		expr, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return data.FilterAnd{Exprs: exprs}, nil
+}
+
+func (p *filterParser) parseUnary(depth int) (data.FilterExpr, error) {
+	if p.keyword("not") {
+		expr, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return data.FilterNot{Expr: expr}, nil
+	}
+
+	if open, ok := p.peek(); ok && open.kind == "(" {
+		p.pos++
+
+		expr, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if t, ok := p.peek(); !ok || t.kind != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis for position %d", open.pos+1)
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	return p.parseCondition()
+}
+
+func (p *filterParser) parseCondition() (data.FilterExpr, error) {
+	p.conditions++
+	if p.conditions > maxFilterConditions {
+		return nil, fmt.Errorf("must not contain more than %d conditions", maxFilterConditions)
+	}
+
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if field.kind != "word" {
+		return nil, fmt.Errorf("expected a field name at position %d", field.pos+1)
+	}
+
+	ops, ok := data.FilterFields[field.value]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", field.value)
+	}
+
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if op.kind != "op" {
+		return nil, fmt.Errorf("expected an operator after %q", field.value)
+	}
+
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if value.kind != "word" && value.kind != "string" {
+		return nil, fmt.Errorf("expected a value after %q", field.value+op.value)
+	}
+
+	if field.value == "genres" {
+		return parseGenresCondition(data.FilterOp(op.value), value.value)
+	}
+
+	if !validator.PermittedValue(data.FilterOp(op.value), ops...) {
+		return nil, fmt.Errorf("operator %q is not supported for %s", op.value, field.value)
+	}
+
+	condition := data.FilterCondition{Field: field.value, Op: data.FilterOp(op.value)}
+
+	switch field.value {
+	case "id", "year", "size":
+		i, err := strconv.ParseInt(value.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be compared with an integer value", field.value)
+		}
+		condition.Value = i
+	case "created_at":
+		t, err := parseFilterTime(value.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be compared with an RFC 3339 time or a YYYY-MM-DD date", field.value)
+		}
+		condition.Value = t
+	default:
+		condition.Value = value.value
+	}
+
+	return condition, nil
+}
+
+// parseGenresCondition handles genres=any:a,b, genres=all:a,b and their !=
+// negations. A bare list means all:.
+func parseGenresCondition(op data.FilterOp, value string) (data.FilterExpr, error) {
+	if op != data.FilterEq && op != data.FilterNe {
+		return nil, fmt.Errorf("operator %q is not supported for genres", op)
+	}
+
+	match := data.FilterAll
+	switch {
+	case strings.HasPrefix(value, "any:"):
+		match, value = data.FilterAny, strings.TrimPrefix(value, "any:")
+	case strings.HasPrefix(value, "all:"):
+		value = strings.TrimPrefix(value, "all:")
+	}
+
+	genres := strings.Split(value, ",")
+	for _, genre := range genres {
+		if strings.TrimSpace(genre) == "" {
+			return nil, errors.New("genres must be compared with a comma-separated list of genres")
+		}
+	}
+
+	var expr data.FilterExpr = data.FilterCondition{Field: "genres", Op: match, Value: genres}
+	if op == data.FilterNe {
+		expr = data.FilterNot{Expr: expr}
+	}
+	return expr, nil
+}
+
+func parseFilterTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"mauk14.library/internal/data"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	year := func(op data.FilterOp, value int64) data.FilterCondition {
+		return data.FilterCondition{Field: "year", Op: op, Value: value}
+	}
+	title := func(value string) data.FilterCondition {
+		return data.FilterCondition{Field: "title", Op: data.FilterEq, Value: value}
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  data.FilterExpr
+	}{
+		{"condition", "year>=1990", year(data.FilterGte, 1990)},
+		{"spaces around the operator", "year != 1990", year(data.FilterNe, 1990)},
+		{
+			"and binds tighter than or",
+			"year<1900 or year>2000 and title=Dune",
+			data.FilterOr{Exprs: []data.FilterExpr{
+				year(data.FilterLt, 1900),
+				data.FilterAnd{Exprs: []data.FilterExpr{year(data.FilterGt, 2000), title("Dune")}},
+			}},
+		},
+		{
+			"and before or",
+			"year<1900 and title=Dune or year>2000",
+			data.FilterOr{Exprs: []data.FilterExpr{
+				data.FilterAnd{Exprs: []data.FilterExpr{year(data.FilterLt, 1900), title("Dune")}},
+				year(data.FilterGt, 2000),
+			}},
+		},
+		{
+			"parentheses",
+			"(year<1900 or year>2000) and title=Dune",
+			data.FilterAnd{Exprs: []data.FilterExpr{
+				data.FilterOr{Exprs: []data.FilterExpr{year(data.FilterLt, 1900), year(data.FilterGt, 2000)}},
+				title("Dune"),
+			}},
+		},
+		{
+			"not binds tightest",
+			"not year=1965 and title=Dune",
+			data.FilterAnd{Exprs: []data.FilterExpr{
+				data.FilterNot{Expr: year(data.FilterEq, 1965)},
+				title("Dune"),
+			}},
+		},
+		{
+			"not of a group",
+			"NOT (year=1965 OR title=Dune)",
+			data.FilterNot{Expr: data.FilterOr{Exprs: []data.FilterExpr{year(data.FilterEq, 1965), title("Dune")}}},
+		},
+		{"quoted value", `title="The Hobbit (Illustrated)"`, title("The Hobbit (Illustrated)")},
+		{"escaped quote", `title="Say \"Hi\""`, title(`Say "Hi"`)},
+		{"keyword as a value", "title=or", title("or")},
+		{"date", "created_at<2024-03-01", data.FilterCondition{Field: "created_at", Op: data.FilterLt, Value: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}},
+		{"all genres by default", `genres="science fiction,classic"`, data.FilterCondition{Field: "genres", Op: data.FilterAll, Value: []string{"science fiction", "classic"}}},
+		{"any genre", "genres=any:fantasy,horror", data.FilterCondition{Field: "genres", Op: data.FilterAny, Value: []string{"fantasy", "horror"}}},
+		{"no genre", "genres!=any:horror", data.FilterNot{Expr: data.FilterCondition{Field: "genres", Op: data.FilterAny, Value: []string{"horror"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFilter(tt.input)
+			if err != nil {
+				t.Fatalf("got error %q", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v; want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "  ", "must not be empty"},
+		{"too long", "title=" + strings.Repeat("a", maxFilterLength), "must not be more than 1000 bytes long"},
+		{"unknown field", "isbn=1", `unknown field "isbn"`},
+		{"missing operator", "year 1990", `expected an operator after "year"`},
+		{"missing value", "year>=", "unexpected end of expression"},
+		{"operator as value", "year>==1990", `operator ">==" is not supported for year`},
+		{"unsupported operator", "title<Dune", `operator "<" is not supported for title`},
+		{"not an integer", "year=soon", "year must be compared with an integer value"},
+		{"not a time", "created_at>yesterday", "created_at must be compared with an RFC 3339 time or a YYYY-MM-DD date"},
+		{"ordered genres", "genres>fantasy", `operator ">" is not supported for genres`},
+		{"empty genre", "genres=any:fantasy,", "genres must be compared with a comma-separated list of genres"},
+		{"unterminated string", `title="Dune`, "unterminated string at position 7"},
+		{"unclosed parenthesis", "(year=1965", "missing closing parenthesis for position 1"},
+		{"stray parenthesis", "year=1965)", `unexpected ")" at position 10`},
+		{"missing and", "year=1965 title=Dune", `unexpected "title" at position 11`},
+		{"dangling or", "year=1965 or", "unexpected end of expression"},
+		{"field expected", "=1965", "expected a field name at position 1"},
+		{"too many conditions", strings.Repeat("year=1 or ", maxFilterConditions) + "year=1", "must not contain more than 20 conditions"},
+		{"too deep", strings.Repeat("(", maxFilterDepth+1) + "year=1" + strings.Repeat(")", maxFilterDepth+1), "must not nest more than 10 levels deep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFilter(tt.input)
+			if err == nil {
+				t.Fatalf("got no error; want %q", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q; want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
 	"io"
 	"mauk14.library/internal/validator"
@@ -24,6 +25,22 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// readISBNParam reads the ISBN of /v1/books/isbn/:isbn, which is routed as
+// /v1/books/:id/:item; see routes.
+func (app *application) readISBNParam(r *http.Request) string {
+	return httprouter.ParamsFromContext(r.Context()).ByName("item")
+}
+
+// readCopyIDParam reads the copy id of /v1/books/:id/copies/:copy_id.
+func (app *application) readCopyIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("copy_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid copy_id parameter")
+	}
+	return id, nil
+}
+
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
 	js, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
@@ -128,6 +145,74 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 
 }
 
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+// etag renders a record version as a strong entity tag.
+func etag(version uuid.UUID) string {
+	return strconv.Quote(version.String())
+}
+
+// matchETag reports whether the If-Match or If-None-Match header value
+// matches tag. A "*" matches any tag. Weak tags only match when weak is
+// true, as If-None-Match allows and If-Match does not.
+func matchETag(header string, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// matchVersion reports whether the If-Match header value names version, by
+// its etag or by a book tag that extends it (see bookETag). A "*" matches any
+// version and weak tags never match.
+func matchVersion(header string, version uuid.UUID) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		tag, err := strconv.Unquote(candidate)
+		if err != nil {
+			continue
+		}
+
+		if tag, _, _ = strings.Cut(tag, "+"); tag == version.String() {
+			return true
+		}
+	}
+	return false
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 
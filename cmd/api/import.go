@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mauk14.library/internal/data"
+	"mauk14.library/internal/validator"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// An import creates its books in one transaction, so it either succeeds or
+// leaves no books behind and can simply be retried. Imports are limited to
+// what one transaction safely holds on every backend, where MongoDB's limits
+// on the size and running time of a transaction are the tightest; larger
+// catalogues are imported over several requests.
+const (
+	maxImportBytes = 4 << 20
+	maxImportRows  = 1_000
+	maxImportLine  = 1 << 20
+)
+
+// importRow is the outcome of one record of an import. Row numbers count
+// records from 1, not counting the CSV header.
+type importRow struct {
+	Row    int               `json:"row"`
+	Status string            `json:"status"`
+	ID     int64             `json:"id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// importReader yields the books of an import one at a time. A per-record
+// problem is returned as an *importRecordError and reading can go on; any
+// other error ends the import.
+type importReader interface {
+	next() (*data.Book, error)
+}
+
+type importRecordError struct {
+	errors map[string]string
+}
+
+func (e *importRecordError) Error() string {
+	return "invalid record"
+}
+
+func (app *application) importBooksHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	dryRun := app.readBool(qs, "dry_run", false, v)
+
+	format := app.readString(qs, "format", "")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = "csv"
+		case "application/x-ndjson", "application/ndjson":
+			format = "ndjson"
+		}
+	}
+	v.Check(format != "", "format", "must be provided as a parameter or through the Content-Type header")
+	v.Check(format == "" || validator.PermittedValue(format, "csv", "ndjson"), "format", "must be csv or ndjson")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var reader importReader
+	switch format {
+	case "csv":
+		var err error
+		reader, err = newCSVImportReader(body)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	case "ndjson":
+		reader = newNDJSONImportReader(body)
+	}
+
+	// The whole import is read and validated before anything is written, so
+	// a malformed body or too many records fail it without creating books.
+	var rows []importRow
+	var books []*data.Book
+
+	for row := 1; ; row++ {
+		book, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if row > maxImportRows {
+			app.badRequestResponse(w, r, fmt.Errorf("import must not contain more than %d records", maxImportRows))
+			return
+		}
+
+		result := importRow{Row: row}
+
+		var recordError *importRecordError
+		switch {
+		case errors.As(err, &recordError):
+			result.Status, result.Errors = "failed", recordError.errors
+		case err != nil:
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				err = fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+			}
+			app.badRequestResponse(w, r, fmt.Errorf("row %d: %w", row, err))
+			return
+		default:
+			v := validator.New()
+
+			data.NormalizeISBNs(book)
+
+			if data.ValidateBook(v, book); !v.Valid() {
+				result.Status, result.Errors = "failed", v.Errors
+			}
+		}
+
+		rows = append(rows, result)
+		books = append(books, book)
+	}
+
+	// The valid books are created in one transaction, so either all of them
+	// are kept or none are and the import can safely be retried. The
+	// transaction may run more than once, so each attempt starts over from
+	// the rows read above.
+	var report []importRow
+
+	save := func(ctx context.Context, models data.Models) error {
+		report = make([]importRow, len(rows))
+		copy(report, rows)
+
+		isbns := make(map[string]bool)
+
+		for i := range report {
+			if report[i].Status != "" {
+				continue
+			}
+
+			book := *books[i]
+
+			if book.ISBN13 != "" {
+				duplicate := isbns[book.ISBN13]
+				if !duplicate {
+					_, err := models.Books.GetByISBN(ctx, book.ISBN13)
+					if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+						return err
+					}
+					duplicate = err == nil
+				}
+				if duplicate {
+					report[i].Status, report[i].Errors = "failed", map[string]string{"isbn_13": "a book with this ISBN already exists"}
+					continue
+				}
+				isbns[book.ISBN13] = true
+			}
+
+			if dryRun {
+				report[i].Status = "valid"
+				continue
+			}
+
+			if err := models.Books.Insert(ctx, &book); err != nil {
+				return err
+			}
+			if err := models.Authors.LinkByName(ctx, book.ID, book.Author); err != nil {
+				return err
+			}
+			report[i].Status, report[i].ID = "created", book.ID
+		}
+
+		return nil
+	}
+
+	var err error
+	if dryRun {
+		err = save(r.Context(), app.models)
+	} else {
+		err = app.models.WithTx(r.Context(), save)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateISBN):
+			// Another request took one of the ISBNs since it was checked.
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	summary := map[string]int{"created": 0, "valid": 0, "failed": 0}
+	for _, result := range report {
+		summary[result.Status]++
+	}
+
+	if summary["created"] != 0 {
+		app.suggestions.clear()
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"dry_run": dryRun, "summary": summary, "rows": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// csvImportReader reads books from CSV with a header row naming the columns
+// title, author, year, size, genres, isbn_10, isbn_13, format, duration and
+// publisher in any order. Sizes may be written as "320" or "320 pages" and
+// durations as "540" or "540 minutes", and genres are separated by
+// semicolons.
+type csvImportReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+var csvImportColumns = []string{"title", "author", "year", "size", "genres", "isbn_10", "isbn_13", "format", "duration", "publisher"}
+
+func newCSVImportReader(body io.Reader) (*csvImportReader, error) {
+	r := csv.NewReader(body)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, fmt.Errorf("body contains a badly-formed CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !validator.PermittedValue(name, csvImportColumns...) {
+			return nil, fmt.Errorf("body contains unknown CSV column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("body contains duplicate CSV column %q", name)
+		}
+		columns[name] = i
+	}
+
+	return &csvImportReader{r: r, columns: columns}, nil
+}
+
+func (c *csvImportReader) next() (*data.Book, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(record) != len(c.columns) {
+		return nil, &importRecordError{map[string]string{"record": fmt.Sprintf("must have %d fields", len(c.columns))}}
+	}
+
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	book := &data.Book{
+		Title:     field("title"),
+		Author:    field("author"),
+		ISBN10:    field("isbn_10"),
+		ISBN13:    field("isbn_13"),
+		Format:    field("format"),
+		Publisher: field("publisher"),
+	}
+	errs := make(map[string]string)
+
+	if s := field("year"); s != "" {
+		year, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			errs["year"] = "must be an integer value"
+		}
+		book.Year = int32(year)
+	}
+
+	if s := strings.TrimSuffix(field("size"), " pages"); s != "" {
+		size, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			errs["size"] = data.ErrInvalidSizeFormat.Error()
+		}
+		book.Size = data.Size(size)
+	}
+
+	if s := strings.TrimSuffix(field("duration"), " minutes"); s != "" {
+		duration, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			errs["duration"] = data.ErrInvalidDurationFormat.Error()
+		}
+		book.Duration = data.Duration(duration)
+	}
+
+	if s := field("genres"); s != "" {
+		book.Genres = []string{}
+		for _, genre := range strings.Split(s, ";") {
+			book.Genres = append(book.Genres, strings.TrimSpace(genre))
+		}
+	}
+
+	if len(errs) != 0 {
+		return nil, &importRecordError{errs}
+	}
+	return book, nil
+}
+
+// ndjsonImportReader reads books from newline-delimited JSON, one object per
+// line in the same shape createBookHandler accepts. Blank lines are skipped.
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+}
+
+func newNDJSONImportReader(body io.Reader) *ndjsonImportReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+	return &ndjsonImportReader{scanner: scanner}
+}
+
+func (n *ndjsonImportReader) next() (*data.Book, error) {
+	var line []byte
+	for len(line) == 0 {
+		if !n.scanner.Scan() {
+			if err := n.scanner.Err(); err != nil {
+				if errors.Is(err, bufio.ErrTooLong) {
+					return nil, fmt.Errorf("line must not be longer than %d bytes", maxImportLine)
+				}
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		line = bytes.TrimSpace(n.scanner.Bytes())
+	}
+
+	var input struct {
+		Title     string        `json:"title"`
+		Author    string        `json:"author"`
+		Year      int32         `json:"year"`
+		Size      data.Size     `json:"size"`
+		Genres    []string      `json:"genres"`
+		ISBN10    string        `json:"isbn_10"`
+		ISBN13    string        `json:"isbn_13"`
+		Format    string        `json:"format"`
+		Duration  data.Duration `json:"duration"`
+		Publisher string        `json:"publisher"`
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&input); err != nil {
+		return nil, &importRecordError{map[string]string{"record": "must be a valid JSON book object: " + err.Error()}}
+	}
+
+	return &data.Book{
+		Title:     input.Title,
+		Author:    input.Author,
+		Year:      input.Year,
+		Size:      input.Size,
+		Genres:    input.Genres,
+		ISBN10:    input.ISBN10,
+		ISBN13:    input.ISBN13,
+		Format:    input.Format,
+		Duration:  input.Duration,
+		Publisher: input.Publisher,
+	}, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mauk14.library/internal/data"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// failingInsertDB wraps the memory backend, failing the insert of any book
+// with the given title.
+type failingInsertDB struct {
+	*data.Memory
+	title string
+}
+
+func (db failingInsertDB) Books() data.BookStore {
+	return failingBookStore{BookStore: db.Memory.Books(), title: db.title}
+}
+
+func (db failingInsertDB) WithTx(ctx context.Context, fn func(ctx context.Context, tx data.DB) error) error {
+	return db.Memory.WithTx(ctx, func(ctx context.Context, tx data.DB) error {
+		return fn(ctx, failingInsertDB{Memory: tx.(*data.Memory), title: db.title})
+	})
+}
+
+type failingBookStore struct {
+	data.BookStore
+	title string
+}
+
+func (s failingBookStore) Insert(ctx context.Context, book *data.Book) error {
+	if book.Title == s.title {
+		return errInjected
+	}
+	return s.BookStore.Insert(ctx, book)
+}
+
+func ndjsonBook(title, isbn string) string {
+	return fmt.Sprintf(`{"title": %q, "author": "Frank Herbert", "year": 1965, "size": "412 pages", "genres": ["science fiction"], "isbn_13": %q}`+"\n", title, isbn)
+}
+
+func countBooks(t *testing.T, app *application) int {
+	t.Helper()
+
+	_, metadata, err := app.models.Books.GetAll(context.Background(), data.BookFilter{}, data.Filters{Page: 1, PageSize: 1, Sort: "id", SortSafelist: []string{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.TotalRecords
+}
+
+func TestImportBooks(t *testing.T) {
+	app := newTestApplication(t, data.NewMemory())
+	token := newTestUser(t, app, "erin@example.com", "books:read", "books:write")
+
+	body := ndjsonBook("Dune", "9780441013593") + "{}\n" + ndjsonBook("Dune again", "9780441013593") + ndjsonBook("Dune Messiah", "")
+
+	res := send(t, app.routes(), token, http.MethodPost, "/v1/books/import?format=ndjson", body)
+	if res.Code != http.StatusOK {
+		t.Fatalf("got status %d; want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+
+	summary := res.object(t, "summary")
+	if summary["created"] != float64(2) || summary["failed"] != float64(2) {
+		t.Errorf("got summary %v; want 2 created and 2 failed", summary)
+	}
+
+	rows := res.body["rows"].([]any)
+	duplicate := rows[2].(map[string]any)
+	if duplicate["status"] != "failed" || duplicate["errors"].(map[string]any)["isbn_13"] == nil {
+		t.Errorf("got row %v; want a failed duplicate ISBN", duplicate)
+	}
+
+	if n := countBooks(t, app); n != 2 {
+		t.Errorf("got %d books; want 2", n)
+	}
+}
+
+// TestImportBooksAllOrNothing checks that an import which cannot finish
+// leaves no books behind, whichever record stops it.
+func TestImportBooksAllOrNothing(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		body   string
+		want   int
+	}{
+		{
+			name:   "line too long",
+			format: "ndjson",
+			body:   ndjsonBook("Dune", "") + `{"title": "` + strings.Repeat("a", maxImportLine) + `"}` + "\n",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "malformed CSV",
+			format: "csv",
+			body:   "title,author,year,size,genres\nDune,Frank Herbert,1965,412,science fiction\n\"Dune Messiah,Frank Herbert,1969,256,science fiction\n",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "too many records",
+			format: "ndjson",
+			body:   ndjsonBook("Dune", "") + strings.Repeat("{}\n", maxImportRows),
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "failed insert",
+			format: "ndjson",
+			body:   ndjsonBook("Dune", "") + ndjsonBook("Fail", "") + ndjsonBook("Dune Messiah", ""),
+			want:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, failingInsertDB{Memory: data.NewMemory(), title: "Fail"})
+			token := newTestUser(t, app, "frank@example.com", "books:read", "books:write")
+
+			res := send(t, app.routes(), token, http.MethodPost, "/v1/books/import?format="+tt.format, tt.body)
+			if res.Code != tt.want {
+				t.Fatalf("got status %d; want %d: %s", res.Code, tt.want, res.Body)
+			}
+
+			if n := countBooks(t, app); n != 0 {
+				t.Errorf("got %d books; want none", n)
+			}
+		})
+	}
+}
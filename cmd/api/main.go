@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -25,7 +26,16 @@ type config struct {
 		enabled bool
 	}
 	db struct {
-		dsn string
+		backend string
+		dsn     string
+		timeout time.Duration
+	}
+	tokens struct {
+		cleanupInterval time.Duration
+	}
+	suggest struct {
+		cacheTTL        time.Duration
+		cacheMaxEntries int
 	}
 	smtp struct {
 		host     string
@@ -41,11 +51,12 @@ const (
 )
 
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config      config
+	logger      *jsonlog.Logger
+	models      data.Models
+	mailer      mailer.Mailer
+	suggestions *suggestionCache
+	wg          sync.WaitGroup
 }
 
 func main() {
@@ -54,13 +65,20 @@ func main() {
 	flag.IntVar(&cfg.port, "port", 4000, "API server port")
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
 
+	flag.StringVar(&cfg.db.backend, "db", "postgres", "Database backend (postgres|mongo|memory)")
 	flag.StringVar(&cfg.db.dsn, "db-dsn", "POSTGRES_URI", "PostgreSQL DSN")
+	flag.DurationVar(&cfg.db.timeout, "db-timeout", 3*time.Second, "Deadline for a single database operation")
 
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
-	flag.BoolVar(&cfg.isMongo, "mongo", false, "Mongo use or not?")
+	flag.DurationVar(&cfg.tokens.cleanupInterval, "token-cleanup-interval", time.Hour, "How often expired tokens are purged (0 disables)")
+
+	flag.DurationVar(&cfg.suggest.cacheTTL, "suggest-cache-ttl", time.Minute, "How long autocomplete results are cached (0 disables)")
+	flag.IntVar(&cfg.suggest.cacheMaxEntries, "suggest-cache-max-entries", 10000, "Maximum number of cached autocomplete results")
+
+	flag.BoolVar(&cfg.isMongo, "mongo", false, "Use MongoDB (shorthand for -db=mongo)")
 
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "SMTP_HOST", "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 587, "SMTP port")
@@ -75,9 +93,14 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if cfg.isMongo {
+		cfg.db.backend = "mongo"
+	}
+
 	var db data.DB
 
-	if cfg.isMongo {
+	switch cfg.db.backend {
+	case "mongo":
 		client, err := clientMongoDb(os.Getenv("MONGODB_URI"))
 
 		if err != nil {
@@ -92,21 +115,27 @@ func main() {
 		}(client, ctx)
 
 		db = data.NewMongo(client, "Library")
-	} else {
+		logger.PrintInfo("database connection pool established", nil)
+	case "postgres":
 		database, err := OpenDb(cfg)
 		if err != nil {
 			logger.PrintFatal(err, nil)
 		}
 		db = database
-
+		logger.PrintInfo("database connection pool established", nil)
+	case "memory":
+		db = data.NewMemory()
+		logger.PrintInfo("using in-memory database", nil)
+	default:
+		logger.PrintFatal(fmt.Errorf("unknown database backend %q", cfg.db.backend), nil)
 	}
-	logger.PrintInfo("database connection pool established", nil)
 
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:      cfg,
+		logger:      logger,
+		models:      data.NewModels(db, cfg.db.timeout),
+		mailer:      mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		suggestions: newSuggestionCache(cfg.suggest.cacheTTL, cfg.suggest.cacheMaxEntries),
 	}
 
 	err := app.serve()
@@ -17,10 +17,35 @@ func (app *application) routes() http.Handler {
 
 	router.HandlerFunc(http.MethodGet, "/v1/books", app.requirePermission("books:read", app.listBooksHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/books", app.requirePermission("books:write", app.createBookHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/books/:id", app.requirePermission("books:read", app.showBookHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/books/:id", app.dispatchParam("id", app.notFoundResponse, map[string]http.HandlerFunc{
+		"import": app.requirePermission("books:write", app.importBooksHandler),
+	}))
+	router.HandlerFunc(http.MethodGet, "/v1/books/:id", app.dispatchParam("id", app.requirePermission("books:read", app.showBookHandler), map[string]http.HandlerFunc{
+		"suggest": app.requirePermission("books:read", app.suggestBooksHandler),
+		"export":  app.requirePermission("books:read", app.exportBooksHandler),
+	}))
+	router.HandlerFunc(http.MethodGet, "/v1/books/:id/:item", app.dispatchParam("id", app.dispatchParam("item", app.notFoundResponse, map[string]http.HandlerFunc{
+		"copies": app.requirePermission("books:read", app.listCopiesHandler),
+	}), map[string]http.HandlerFunc{
+		"isbn": app.requirePermission("books:read", app.showBookByISBNHandler),
+	}))
 	router.HandlerFunc(http.MethodPatch, "/v1/books/:id", app.requirePermission("books:write", app.updateBookHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/books/:id", app.requirePermission("books:write", app.deleteBookHandler))
 
+	router.HandlerFunc(http.MethodPost, "/v1/books/:id/copies", app.requirePermission("copies:write", app.createCopyHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/books/:id/:item/:copy_id", app.dispatchParam("item", app.notFoundResponse, map[string]http.HandlerFunc{
+		"copies": app.requirePermission("books:read", app.showCopyHandler),
+	}))
+	router.HandlerFunc(http.MethodPatch, "/v1/books/:id/copies/:copy_id", app.requirePermission("copies:write", app.updateCopyHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/books/:id/copies/:copy_id", app.requirePermission("copies:write", app.deleteCopyHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/authors", app.requirePermission("books:read", app.listAuthorsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/authors", app.requirePermission("books:write", app.createAuthorHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/authors/:id", app.requirePermission("books:read", app.showAuthorHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/authors/:id", app.requirePermission("books:write", app.updateAuthorHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/authors/:id", app.requirePermission("books:write", app.deleteAuthorHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/authors/:id/books", app.requirePermission("books:read", app.listAuthorBooksHandler))
+
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 
@@ -28,3 +53,19 @@ func (app *application) routes() http.Handler {
 
 	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router))))
 }
+
+// dispatchParam routes requests by the value of the named path parameter.
+// httprouter does not allow a static segment such as /v1/books/suggest next to
+// the /v1/books/:id wildcard, so those routes are registered under the
+// wildcard and picked out here; any other value goes to fallback.
+func (app *application) dispatchParam(name string, fallback http.HandlerFunc, routes map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := httprouter.ParamsFromContext(r.Context()).ByName(name)
+
+		if next, ok := routes[value]; ok {
+			next(w, r)
+			return
+		}
+		fallback(w, r)
+	}
+}
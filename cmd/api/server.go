@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,12 +13,22 @@ import (
 )
 
 func (app *application) serve() error {
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.config.port),
 		Handler:      app.routes(),
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+
+	if app.config.tokens.cleanupInterval > 0 {
+		go app.purgeExpiredTokens(baseCtx, app.config.tokens.cleanupInterval)
 	}
 
 	shutdownError := make(chan error)
@@ -36,6 +47,11 @@ func (app *application) serve() error {
 		defer cancel()
 
 		err := srv.Shutdown(ctx)
+
+		// Requests still running after the grace period have their contexts
+		// cancelled, which aborts any database work they are waiting on.
+		cancelBase()
+
 		if err != nil {
 			shutdownError <- err
 		}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mauk14.library/internal/data"
+	"mauk14.library/internal/jsonlog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestApplication returns an application backed by db, with logging
+// turned off and the rate limiter disabled.
+func newTestApplication(t *testing.T, db data.DB) *application {
+	t.Helper()
+
+	return &application{
+		logger:      jsonlog.New(io.Discard, jsonlog.LevelOff),
+		models:      data.NewModels(db, time.Second),
+		suggestions: newSuggestionCache(time.Minute, 100),
+	}
+}
+
+// newTestUser registers an activated user holding permissions and returns a
+// bearer token for it.
+func newTestUser(t *testing.T, app *application, email string, permissions ...string) string {
+	t.Helper()
+
+	ctx := context.Background()
+
+	user := &data.User{Name: "Test User", Email: email, Activated: true}
+	if err := user.Password.Set("pa55word1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Users.Insert(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(ctx, user.ID, permissions...); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := app.models.Tokens.New(ctx, user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token.Plaintext
+}
+
+type testResponse struct {
+	*httptest.ResponseRecorder
+	body map[string]any
+}
+
+// send serves one request against h. A string body is sent as it is and any
+// other non-nil body as JSON. A JSON response is decoded into the body of the
+// result.
+func send(t *testing.T, h http.Handler, token, method, url string, body any) testResponse {
+	t.Helper()
+
+	return serve(t, h, newTestRequest(t, token, method, url, body))
+}
+
+// newTestRequest builds the request send serves, for tests that need to set
+// more headers on it.
+func newTestRequest(t *testing.T, token, method, url string, body any) *http.Request {
+	t.Helper()
+
+	var r io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(body)
+	default:
+		js, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(js)
+	}
+
+	req := httptest.NewRequest(method, url, r)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func serve(t *testing.T, h http.Handler, req *http.Request) testResponse {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	res := testResponse{ResponseRecorder: rr}
+	if rr.Body.Len() != 0 && rr.Header().Get("Content-Type") == "application/json" {
+		if err := json.Unmarshal(rr.Body.Bytes(), &res.body); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", req.Method, req.URL, err)
+		}
+	}
+	return res
+}
+
+// object returns the JSON object under key in the response body.
+func (res testResponse) object(t *testing.T, key string) map[string]any {
+	t.Helper()
+
+	m, ok := res.body[key].(map[string]any)
+	if !ok {
+		t.Fatalf("response has no %q object: %s", key, res.ResponseRecorder.Body)
+	}
+	return m
+}
+
+func testBook(title string) map[string]any {
+	return map[string]any{
+		"title":  title,
+		"author": "Frank Herbert",
+		"year":   1965,
+		"size":   "412 pages",
+		"genres": []string{"science fiction"},
+	}
+}
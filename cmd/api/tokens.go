@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"mauk14.library/internal/data"
 	"mauk14.library/internal/validator"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -30,7 +32,7 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
-	user, err := app.models.Users.GetByEmail(input.Email)
+	user, err := app.models.Users.GetByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -52,7 +54,7 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	token, err := app.models.Tokens.New(r.Context(), user.ID, 24*time.Hour, data.ScopeAuthentication)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -64,3 +66,29 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	}
 
 }
+
+// purgeExpiredTokens deletes expired tokens every interval until ctx is
+// cancelled. Expired tokens are already rejected on use; this only keeps
+// the tokens table from growing without bound.
+func (app *application) purgeExpiredTokens(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := app.models.Tokens.DeleteExpired(ctx)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				continue
+			}
+			if deleted > 0 {
+				app.logger.PrintInfo("purged expired tokens", map[string]string{
+					"count": strconv.FormatInt(deleted, 10),
+				})
+			}
+		}
+	}
+}
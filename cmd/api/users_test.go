@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"mauk14.library/internal/data"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var errInjected = errors.New("injected failure")
+
+// failingDB wraps the memory backend, failing one step of registration so
+// the tests can check that nothing of the user survives the rollback.
+type failingDB struct {
+	*data.Memory
+	step string
+}
+
+func (db failingDB) Users() data.UserStore {
+	return failingUserStore{UserStore: db.Memory.Users(), fail: db.step == "users"}
+}
+
+func (db failingDB) Permissions() data.PermissionStore {
+	return failingPermissionStore{PermissionStore: db.Memory.Permissions(), fail: db.step == "permissions"}
+}
+
+func (db failingDB) Tokens() data.TokenStore {
+	return failingTokenStore{TokenStore: db.Memory.Tokens(), fail: db.step == "tokens"}
+}
+
+func (db failingDB) WithTx(ctx context.Context, fn func(ctx context.Context, tx data.DB) error) error {
+	return db.Memory.WithTx(ctx, func(ctx context.Context, tx data.DB) error {
+		return fn(ctx, failingDB{Memory: tx.(*data.Memory), step: db.step})
+	})
+}
+
+type failingUserStore struct {
+	data.UserStore
+	fail bool
+}
+
+func (s failingUserStore) Insert(ctx context.Context, user *data.User) error {
+	if s.fail {
+		return errInjected
+	}
+	return s.UserStore.Insert(ctx, user)
+}
+
+type failingPermissionStore struct {
+	data.PermissionStore
+	fail bool
+}
+
+func (s failingPermissionStore) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	if s.fail {
+		return errInjected
+	}
+	return s.PermissionStore.AddForUser(ctx, userID, codes...)
+}
+
+type failingTokenStore struct {
+	data.TokenStore
+	fail bool
+}
+
+func (s failingTokenStore) Insert(ctx context.Context, token *data.Token) error {
+	if s.fail {
+		return errInjected
+	}
+	return s.TokenStore.Insert(ctx, token)
+}
+
+func TestRegisterUserRollsBack(t *testing.T) {
+	const email = "dave@example.com"
+
+	for _, step := range []string{"users", "permissions", "tokens"} {
+		t.Run(step, func(t *testing.T) {
+			memory := data.NewMemory()
+			app := newTestApplication(t, failingDB{Memory: memory, step: step})
+
+			input := map[string]any{"name": "Dave", "email": email, "password": "pa55word1234"}
+
+			res := send(t, app.routes(), "", http.MethodPost, "/v1/users", input)
+			if res.Code != http.StatusInternalServerError {
+				t.Fatalf("got status %d; want %d", res.Code, http.StatusInternalServerError)
+			}
+
+			models := data.NewModels(memory, time.Second)
+
+			_, err := models.Users.GetByEmail(context.Background(), email)
+			if !errors.Is(err, data.ErrRecordNotFound) {
+				t.Errorf("got error %v looking up the user; want ErrRecordNotFound", err)
+			}
+
+			// The email must be free for another attempt.
+			user := &data.User{Name: "Dave", Email: email}
+			if err := user.Password.Set("pa55word1234"); err != nil {
+				t.Fatal(err)
+			}
+			if err := models.Users.Insert(context.Background(), user); err != nil {
+				t.Errorf("got error %v registering again; want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"mauk14.library/internal/jsonlog"
+	"os"
+	"strconv"
+	"time"
+)
+
+type config struct {
+	backend string
+	dsn     string
+	path    string
+	timeout time.Duration
+	mongo   struct {
+		uri      string
+		database string
+	}
+}
+
+// driver applies migrations to one kind of database and records which
+// versions have been applied in that same database.
+type driver interface {
+	applied(ctx context.Context) (map[int64]bool, error)
+	up(ctx context.Context, m migration) error
+	down(ctx context.Context, m migration) error
+	close(ctx context.Context) error
+}
+
+type migrator struct {
+	driver     driver
+	migrations []migration
+	logger     *jsonlog.Logger
+}
+
+func main() {
+	var cfg config
+
+	flag.StringVar(&cfg.backend, "db", "postgres", "Database backend (postgres|mongo)")
+	flag.StringVar(&cfg.dsn, "db-dsn", os.Getenv("POSTGRES_URI"), "PostgreSQL DSN")
+	flag.StringVar(&cfg.mongo.uri, "mongo-uri", os.Getenv("MONGODB_URI"), "MongoDB connection URI")
+	flag.StringVar(&cfg.mongo.database, "mongo-database", "Library", "MongoDB database name")
+	flag.StringVar(&cfg.path, "path", "", "Migrations directory (default ./migrations, or ./migrations/mongo for -db=mongo)")
+	flag.DurationVar(&cfg.timeout, "timeout", time.Minute, "Deadline for the whole command")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] up [N] | down [N] | goto VERSION | status\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var ext string
+
+	switch cfg.backend {
+	case "postgres":
+		ext = ".sql"
+		if cfg.path == "" {
+			cfg.path = "./migrations"
+		}
+	case "mongo":
+		ext = ".json"
+		if cfg.path == "" {
+			cfg.path = "./migrations/mongo"
+		}
+	default:
+		logger.PrintFatal(fmt.Errorf("unknown database backend %q", cfg.backend), nil)
+	}
+
+	migrations, err := loadMigrations(cfg.path, ext)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	defer cancel()
+
+	var d driver
+
+	switch cfg.backend {
+	case "postgres":
+		d, err = openPostgres(ctx, cfg.dsn)
+	case "mongo":
+		d, err = openMongo(ctx, cfg.mongo.uri, cfg.mongo.database)
+	}
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	m := &migrator{driver: d, migrations: migrations, logger: logger}
+
+	err = m.run(ctx, flag.Arg(0), flag.Args()[1:])
+
+	if closeErr := d.close(ctx); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+}
+
+func (m *migrator) run(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "up":
+		n, err := countArg(args)
+		if err != nil {
+			return err
+		}
+		return m.up(ctx, n)
+	case "down":
+		n, err := countArg(args)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			n = 1
+		}
+		return m.down(ctx, n)
+	case "goto":
+		if len(args) != 1 {
+			return errors.New("goto needs exactly one version")
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		return m.goTo(ctx, version)
+	case "status":
+		return m.status(ctx)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// countArg parses the optional step count of up and down. Zero means no
+// limit was given.
+func countArg(args []string) (int, error) {
+	switch len(args) {
+	case 0:
+		return 0, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid step count %q", args[0])
+		}
+		return n, nil
+	default:
+		return 0, errors.New("too many arguments")
+	}
+}
+
+// up applies pending migrations in ascending order, at most n of them when
+// n is positive.
+func (m *migrator) up(ctx context.Context, n int) error {
+	applied, err := m.driver.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if applied[mig.version] {
+			continue
+		}
+		if n > 0 && count == n {
+			break
+		}
+
+		err := m.driver.up(ctx, mig)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) up: %w", mig.version, mig.name, err)
+		}
+		m.logger.PrintInfo("applied migration", map[string]string{"version": strconv.FormatInt(mig.version, 10), "name": mig.name})
+		count++
+	}
+
+	if count == 0 {
+		m.logger.PrintInfo("no change", nil)
+	}
+	return nil
+}
+
+// down reverts the n most recently applied migrations.
+func (m *migrator) down(ctx context.Context, n int) error {
+	applied, err := m.driver.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < n; i-- {
+		mig := m.migrations[i]
+		if !applied[mig.version] {
+			continue
+		}
+
+		err := m.driver.down(ctx, mig)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) down: %w", mig.version, mig.name, err)
+		}
+		m.logger.PrintInfo("reverted migration", map[string]string{"version": strconv.FormatInt(mig.version, 10), "name": mig.name})
+		count++
+	}
+
+	if count == 0 {
+		m.logger.PrintInfo("no change", nil)
+	}
+	return nil
+}
+
+// goTo applies every migration up to and including version and reverts
+// every applied migration above it. Version 0 reverts everything.
+func (m *migrator) goTo(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("no migration with version %d", version)
+	}
+
+	applied, err := m.driver.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.version <= version || !applied[mig.version] {
+			continue
+		}
+
+		err := m.driver.down(ctx, mig)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) down: %w", mig.version, mig.name, err)
+		}
+		m.logger.PrintInfo("reverted migration", map[string]string{"version": strconv.FormatInt(mig.version, 10), "name": mig.name})
+	}
+
+	for _, mig := range m.migrations {
+		if mig.version > version || applied[mig.version] {
+			continue
+		}
+
+		err := m.driver.up(ctx, mig)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) up: %w", mig.version, mig.name, err)
+		}
+		m.logger.PrintInfo("applied migration", map[string]string{"version": strconv.FormatInt(mig.version, 10), "name": mig.name})
+	}
+
+	return nil
+}
+
+func (m *migrator) status(ctx context.Context) error {
+	applied, err := m.driver.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range m.migrations {
+		state := "pending"
+		if applied[mig.version] {
+			state = "applied"
+		}
+		fmt.Printf("%06d  %-8s %s\n", mig.version, state, mig.name)
+	}
+
+	for version := range applied {
+		if m.find(version) == nil {
+			fmt.Printf("%06d  %-8s %s\n", version, "applied", "(missing from the migrations directory)")
+		}
+	}
+
+	return nil
+}
+
+func (m *migrator) find(version int64) *migration {
+	for i := range m.migrations {
+		if m.migrations[i].version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// migration is a pair of NNNNNN_name.up<ext> and NNNNNN_name.down<ext>
+// scripts. Either script may be empty.
+type migration struct {
+	version  int64
+	name     string
+	upPath   string
+	downPath string
+}
+
+var migrationFileRX = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)(\.\w+)$`)
+
+func loadMigrations(dir string, ext string) ([]migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*migration)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		parts := migrationFileRX.FindStringSubmatch(entry.Name())
+		if parts == nil || parts[4] != ext {
+			continue
+		}
+
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{version: version, name: parts[2]}
+			byVersion[version] = mig
+		}
+		if mig.name != parts[2] {
+			return nil, fmt.Errorf("migration %d has files with different names: %s and %s", version, mig.name, parts[2])
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		switch parts[3] {
+		case "up":
+			mig.upPath = path
+		case "down":
+			mig.downPath = path
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.upPath == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", mig.version, mig.name)
+		}
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+func readScript(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
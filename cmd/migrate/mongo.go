@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"time"
+)
+
+// mongoDriver runs migrations written as a JSON array of database commands
+// in MongoDB Extended JSON, e.g. [{"createIndexes": "books", "indexes": [...]}].
+// Commands run one by one and are not transactional: if one fails, the
+// ones before it stay applied and the version is not recorded.
+type mongoDriver struct {
+	client *mongo.Client
+	db     *mongo.Database
+}
+
+func openMongo(ctx context.Context, uri string, database string) (*mongoDriver, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.Ping(ctx, readpref.Primary())
+	if err != nil {
+		client.Disconnect(ctx)
+		return nil, err
+	}
+
+	return &mongoDriver{client: client, db: client.Database(database)}, nil
+}
+
+func (d *mongoDriver) applied(ctx context.Context) (map[int64]bool, error) {
+	cursor, err := d.db.Collection("schema_migrations").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []struct {
+		Version int64 `bson:"version"`
+	}
+
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]bool, len(docs))
+	for _, doc := range docs {
+		applied[doc.Version] = true
+	}
+
+	return applied, nil
+}
+
+func (d *mongoDriver) up(ctx context.Context, m migration) error {
+	err := d.runScript(ctx, m.upPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Collection("schema_migrations").InsertOne(ctx, bson.M{
+		"version":    m.version,
+		"name":       m.name,
+		"applied_at": time.Now(),
+	})
+	return err
+}
+
+func (d *mongoDriver) down(ctx context.Context, m migration) error {
+	err := d.runScript(ctx, m.downPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Collection("schema_migrations").DeleteOne(ctx, bson.M{"version": m.version})
+	return err
+}
+
+func (d *mongoDriver) runScript(ctx context.Context, path string) error {
+	script, err := readScript(path)
+	if err != nil || len(script) == 0 {
+		return err
+	}
+
+	var commands []bson.D
+
+	err = bson.UnmarshalExtJSON(script, false, &commands)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i, command := range commands {
+		err := d.db.RunCommand(ctx, command).Err()
+		if err != nil {
+			return fmt.Errorf("%s: command %d: %w", path, i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *mongoDriver) close(ctx context.Context) error {
+	return d.client.Disconnect(ctx)
+}
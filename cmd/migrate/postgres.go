@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type postgresDriver struct {
+	db *pgxpool.Pool
+}
+
+func openPostgres(ctx context.Context, dsn string) (*postgresDriver, error) {
+	db, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint PRIMARY KEY,
+			name text NOT NULL,
+			applied_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+		)`
+
+	_, err = db.Exec(ctx, query)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &postgresDriver{db: db}, nil
+}
+
+func (d *postgresDriver) applied(ctx context.Context) (map[int64]bool, error) {
+	rows, err := d.db.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+
+	for rows.Next() {
+		var version int64
+
+		err := rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// up runs the script and records the version in one transaction, so a
+// failing migration leaves neither schema changes nor a version row behind.
+func (d *postgresDriver) up(ctx context.Context, m migration) error {
+	script, err := readScript(m.upPath)
+	if err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, d.db, func(tx pgx.Tx) error {
+		if len(script) > 0 {
+			_, err := tx.Exec(ctx, string(script))
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name)
+		return err
+	})
+}
+
+func (d *postgresDriver) down(ctx context.Context, m migration) error {
+	script, err := readScript(m.downPath)
+	if err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, d.db, func(tx pgx.Tx) error {
+		if len(script) > 0 {
+			_, err := tx.Exec(ctx, string(script))
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.version)
+		return err
+	})
+}
+
+func (d *postgresDriver) close(context.Context) error {
+	d.db.Close()
+	return nil
+}
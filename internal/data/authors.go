@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"mauk14.library/internal/validator"
+	"regexp"
+	"time"
+)
+
+// ErrUnknownAuthor is returned when a book is linked to an author that does
+// not exist.
+var ErrUnknownAuthor = errors.New("unknown author")
+
+// AuthorRoles lists the parts a person can play in a book.
+var AuthorRoles = []string{"author", "editor", "translator", "illustrator"}
+
+type Author struct {
+	ID        int64     `json:"id" bson:"id"`
+	CreatedAt time.Time `json:"-" bson:"created_at"`
+	Name      string    `json:"name" bson:"name"`
+	Version   uuid.UUID `json:"version" bson:"version"`
+}
+
+// BookAuthor links a book to one of its authors. A person can hold several
+// roles in the same book, each as its own link.
+type BookAuthor struct {
+	AuthorID int64  `json:"author_id" bson:"author_id"`
+	Name     string `json:"name,omitempty" bson:"-"`
+	Role     string `json:"role" bson:"role"`
+}
+
+type AuthorModel struct {
+	Store   AuthorStore
+	Timeout time.Duration
+}
+
+func (m *AuthorModel) Insert(ctx context.Context, author *Author) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	author.Version = uuid.New()
+	author.CreatedAt = time.Now()
+
+	return m.Store.Insert(ctx, author)
+}
+
+func (m *AuthorModel) Get(ctx context.Context, id int64) (*Author, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	return m.Store.Get(ctx, id)
+}
+
+// GetAll lists the authors whose name contains name, ignoring case.
+func (m *AuthorModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Author, Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.GetAll(ctx, name, filters)
+}
+
+func (m *AuthorModel) Update(ctx context.Context, author *Author) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.Update(ctx, author)
+}
+
+// Delete deletes an author along with its links to books. The books keep
+// their Author text.
+func (m *AuthorModel) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	return m.Store.Delete(ctx, id)
+}
+
+// GetForBooks returns the authors of each of the books, in the order they
+// were set, with their names filled in.
+func (m *AuthorModel) GetForBooks(ctx context.Context, bookIDs []int64) (map[int64][]BookAuthor, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.GetForBooks(ctx, bookIDs)
+}
+
+// SetForBook replaces the authors of a book. It returns ErrRecordNotFound if
+// the book does not exist and ErrUnknownAuthor if one of the authors does
+// not.
+func (m *AuthorModel) SetForBook(ctx context.Context, bookID int64, authors []BookAuthor) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.SetForBook(ctx, bookID, authors)
+}
+
+// LinkByName links a book without authors to the author named exactly name,
+// as the migration that introduced authors linked the books of the time. It
+// does nothing if the book already has authors or no author has that name,
+// and picks the oldest author if several do.
+func (m *AuthorModel) LinkByName(ctx context.Context, bookID int64, name string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.LinkByName(ctx, bookID, name)
+}
+
+// ExistsByName reports whether an author is named exactly name, that is
+// whether LinkByName could link a book to it.
+func (m *AuthorModel) ExistsByName(ctx context.Context, name string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.ExistsByName(ctx, name)
+}
+
+func ValidateAuthor(v *validator.Validator, author *Author) {
+	v.Check(author.Name != "", "name", "must be provided")
+	v.Check(len(author.Name) <= 500, "name", "must not be more than 500 bytes long")
+}
+
+func ValidateBookAuthors(v *validator.Validator, authors []BookAuthor) {
+	v.Check(len(authors) <= 20, "authors", "must not contain more than 20 authors")
+
+	links := make([]BookAuthor, len(authors))
+	for i, author := range authors {
+		v.Check(author.AuthorID > 0, "authors", "must contain valid author ids")
+		v.Check(validator.PermittedValue(author.Role, AuthorRoles...), "authors", "must contain roles of author, editor, translator or illustrator")
+		links[i] = BookAuthor{AuthorID: author.AuthorID, Role: author.Role}
+	}
+	v.Check(validator.Unique(links), "authors", "must not contain duplicate values")
+}
+
+// authorNamePattern is the pattern the stores match author names with, the
+// same contains match BookFilter uses by default.
+func authorNamePattern(name string) string {
+	return regexp.QuoteMeta(name)
+}
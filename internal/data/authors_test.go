@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeleteAuthorBumpsBookVersions(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	author := &Author{Name: "Frank Herbert"}
+	if err := models.Authors.Insert(ctx, author); err != nil {
+		t.Fatal(err)
+	}
+
+	linked, unlinked := newTestBook("Dune"), newTestBook("Dune Messiah")
+	for _, book := range []*Book{linked, unlinked} {
+		if err := models.Books.Insert(ctx, book); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := models.Authors.SetForBook(ctx, linked.ID, []BookAuthor{{AuthorID: author.ID, Role: "author"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Authors.Delete(ctx, author.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		book    *Book
+		changed bool
+	}{
+		{linked, true},
+		{unlinked, false},
+	}
+
+	for _, tt := range tests {
+		got, err := models.Books.Get(ctx, tt.book.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed := got.Version != tt.book.Version; changed != tt.changed {
+			t.Errorf("%s: got version changed %t; want %t", tt.book.Title, changed, tt.changed)
+		}
+	}
+
+	links, err := models.Authors.GetForBooks(ctx, []int64{linked.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links[linked.ID]) != 0 {
+		t.Errorf("got links %v; want none", links[linked.ID])
+	}
+}
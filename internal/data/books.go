@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/mongo"
 	"mauk14.library/internal/validator"
+	"regexp"
+	"regexp/syntax"
 	"time"
 )
 
+var ErrDuplicateISBN = errors.New("duplicate isbn")
+
 type Book struct {
 	ID        int64     `json:"id"`
 	CreatedAt time.Time `json:"-"`
@@ -17,100 +20,196 @@ type Book struct {
 	Year      int32     `json:"year,omitempty"`
 	Size      Size      `json:"-"`
 	Genres    []string  `json:"genres,omitempty"`
+	ISBN10    string    `json:"isbn_10,omitempty" bson:"isbn_10"`
+	ISBN13    string    `json:"isbn_13,omitempty" bson:"isbn_13"`
+	WorkID    int64     `json:"work_id" bson:"work_id"`
+	Format    string    `json:"format,omitempty" bson:"format"`
+	Duration  Duration  `json:"duration,omitempty" bson:"duration"`
+	Publisher string    `json:"publisher,omitempty" bson:"publisher"`
 	Version   uuid.UUID `json:"version"`
+	Score     float64   `json:"score,omitempty" bson:"score,omitempty"`
 }
 
-type BookModel struct {
-	DB DB
+// BookFilter holds the search criteria of a book listing. Zero values match
+// every book, so an empty BookFilter selects the whole catalog.
+type BookFilter struct {
+	Title    string
+	Author   string
+	Match    string
+	Genres   []string
+	Expr     FilterExpr
+	Search   *TextSearch
+	AuthorID int64
+	// WorkIDs limits the books to the editions of these works, and
+	// FirstEditions to the first edition of each work, so that every work
+	// is listed once.
+	WorkIDs       []int64
+	FirstEditions bool
 }
 
-func (b *BookModel) Insert(book *Book) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// Match modes for the Title and Author of a BookFilter. All of them ignore
+// case, and only MatchRegex interprets the input as a pattern.
+const (
+	MatchContains = "contains"
+	MatchPrefix   = "prefix"
+	MatchExact    = "exact"
+	MatchRegex    = "regex"
+)
+
+// pattern turns the title or author s into the regular expression the stores
+// match with. The pattern is written in the subset of syntax that Go, PCRE
+// and PostgreSQL agree on.
+func (f BookFilter) pattern(s string) string {
+	switch f.Match {
+	case MatchPrefix:
+		return "^" + regexp.QuoteMeta(s)
+	case MatchExact:
+		return "^" + regexp.QuoteMeta(s) + "$"
+	case MatchRegex:
+		return s
+	default:
+		return regexp.QuoteMeta(s)
+	}
+}
+
+func ValidateBookFilter(v *validator.Validator, f BookFilter) {
+	v.Check(validator.PermittedValue(f.Match, "", MatchContains, MatchPrefix, MatchExact, MatchRegex), "match", "invalid match value")
+
+	if f.Match != MatchRegex {
+		v.Check(len(f.Title) <= 500, "title", "must not be more than 500 bytes long")
+		v.Check(len(f.Author) <= 500, "author", "must not be more than 500 bytes long")
+		return
+	}
+
+	for key, value := range map[string]string{"title": f.Title, "author": f.Author} {
+		if value == "" {
+			continue
+		}
+		if err := validatePattern(value); err != nil {
+			v.AddError(key, err.Error())
+		}
+	}
+}
 
-	id, err := b.DB.GetLastId(ctx, "", "books")
+// validatePattern rejects regular expressions that are invalid, or that risk
+// running for a long time in a backtracking engine such as MongoDB's.
+func validatePattern(pattern string) error {
+	if len(pattern) > 100 {
+		return errors.New("must not be more than 100 bytes long")
+	}
 
+	re, err := syntax.Parse(pattern, syntax.Perl)
 	if err != nil {
-		return err
+		return errors.New("must be a valid regular expression")
+	}
+
+	nodes := 0
+	var walk func(re *syntax.Regexp, repeated bool) error
+	walk = func(re *syntax.Regexp, repeated bool) error {
+		nodes++
+		if nodes > 50 {
+			return errors.New("must not be more than 50 regular expression terms")
+		}
+
+		switch re.Op {
+		case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+			if repeated {
+				return errors.New("must not nest repetitions")
+			}
+			if re.Op == syntax.OpRepeat && re.Max > 100 {
+				return errors.New("must not repeat more than 100 times")
+			}
+			repeated = true
+		}
+
+		for _, sub := range re.Sub {
+			if err := walk(sub, repeated); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	book.ID = id + 1
+	return walk(re, false)
+}
+
+type BookModel struct {
+	Store   BookStore
+	Timeout time.Duration
+}
+
+func (b *BookModel) Insert(ctx context.Context, book *Book) error {
+	ctx, cancel := withTimeout(ctx, b.Timeout)
+	defer cancel()
+
 	book.Version = uuid.New()
 	book.CreatedAt = time.Now()
 
-	return b.DB.Insert(ctx, "", "books", book)
+	return b.Store.Insert(ctx, book)
 
 }
 
-func (m *BookModel) Get(id int64) (*Book, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// Get fetches a book. When fields are given, only those are guaranteed to be
+// loaded, along with the id and version.
+func (m *BookModel) Get(ctx context.Context, id int64, fields ...string) (*Book, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
-	var book Book
-	var result interface{}
+	return m.Store.Get(ctx, id, bookProjection(fields)...)
 
-	result, err := m.DB.Get(ctx, "", id, "books", "")
-	if err != nil {
-		switch {
-		case errors.Is(err, mongo.ErrNoDocuments):
-			return nil, ErrRecordNotFound
-		default:
-			return nil, err
-		}
+}
 
-	}
+// GetByISBN fetches the book with the given ISBN-13.
+func (m *BookModel) GetByISBN(ctx context.Context, isbn13 string) (*Book, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	switch result.(type) {
-	case Book:
-		book = result.(Book)
+	if isbn13 == "" {
+		return nil, ErrRecordNotFound
 	}
 
-	return &book, nil
-
+	return m.Store.GetByISBN(ctx, isbn13)
 }
 
-func (m *BookModel) Update(book *Book) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *BookModel) Update(ctx context.Context, book *Book) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	err := m.DB.Update(ctx, "", book)
+	return m.Store.Update(ctx, book)
+}
 
-	if err != nil {
-		switch {
-		case errors.Is(err, mongo.ErrNoDocuments):
-			return ErrEditConflict
-		default:
-			return err
-		}
+func (m *BookModel) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	if id < 1 {
+		return ErrRecordNotFound
 	}
 
-	return nil
+	err := m.Store.Delete(ctx, id)
+	return err
 }
 
-func (m *BookModel) Delete(id int64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *BookModel) DeleteVersion(ctx context.Context, id int64, version uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	err := m.DB.Delete(ctx, "", id, "books", "")
-	return err
+	return m.Store.DeleteVersion(ctx, id, version)
 }
 
-func (m *BookModel) GetAll(title string, author string, genres []string, filters Filters) ([]*Book, Metadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *BookModel) GetAll(ctx context.Context, filter BookFilter, filters Filters) ([]*Book, Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
-	opt := make(map[string]string)
-	opt["title"] = title
-	opt["author"] = author
 
-	return m.DB.GetAll(ctx, "", "books", opt, genres, filters)
+	return m.Store.GetAll(ctx, filter, filters)
 
 }
 
@@ -125,11 +224,46 @@ func ValidateBook(v *validator.Validator, Book *Book) {
 	v.Check(Book.Year >= 1888, "year", "must be greater than 1888")
 	v.Check(Book.Year <= int32(time.Now().Year()), "year", "must not be in the future")
 
-	v.Check(Book.Size != 0, "size", "must be provided")
-	v.Check(Book.Size > 0, "size", "must be a positive integer")
+	v.Check(Book.Format == "" || validator.PermittedValue(Book.Format, EditionFormats...), "format", "must be hardcover, paperback, ebook or audiobook")
+
+	if Book.Format == FormatAudiobook {
+		v.Check(Book.Duration != 0, "duration", "must be provided")
+		v.Check(Book.Duration > 0, "duration", "must be a positive integer")
+		v.Check(Book.Size == 0, "size", "must not be provided for an audiobook")
+	} else {
+		v.Check(Book.Size != 0, "size", "must be provided")
+		v.Check(Book.Size > 0, "size", "must be a positive integer")
+		v.Check(Book.Duration == 0, "duration", "must only be provided for an audiobook")
+	}
+
+	v.Check(len(Book.Publisher) <= 500, "publisher", "must not be more than 500 bytes long")
 
 	v.Check(Book.Genres != nil, "genres", "must be provided")
 	v.Check(len(Book.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(Book.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(Book.Genres), "genres", "must not contain duplicate values")
+
+	valid10 := Book.ISBN10 == "" || validator.ValidISBN10(Book.ISBN10)
+	valid13 := Book.ISBN13 == "" || validator.ValidISBN13(Book.ISBN13)
+	v.Check(valid10, "isbn_10", "must be a valid ISBN-10")
+	v.Check(valid13, "isbn_13", "must be a valid ISBN-13")
+
+	if valid10 && valid13 && Book.ISBN10 != "" && Book.ISBN13 != "" {
+		v.Check(validator.ISBN10To13(Book.ISBN10) == Book.ISBN13, "isbn_13", "must identify the same book as isbn_10")
+	}
+}
+
+// NormalizeISBNs normalizes the ISBNs of book and fills in whichever of them
+// is missing from the other, so a book can be looked up by either form.
+// Books with a 979 ISBN-13 have no ISBN-10.
+func NormalizeISBNs(book *Book) {
+	book.ISBN10 = validator.NormalizeISBN(book.ISBN10)
+	book.ISBN13 = validator.NormalizeISBN(book.ISBN13)
+
+	switch {
+	case book.ISBN13 == "" && validator.ValidISBN10(book.ISBN10):
+		book.ISBN13 = validator.ISBN10To13(book.ISBN10)
+	case book.ISBN10 == "" && validator.ValidISBN13(book.ISBN13):
+		book.ISBN10, _ = validator.ISBN13To10(book.ISBN13)
+	}
 }
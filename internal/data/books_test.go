@@ -0,0 +1,44 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func newTestBook(title string) *Book {
+	return &Book{Title: title, Author: "Frank Herbert", Year: 1965, Size: 412, Genres: []string{"science fiction"}}
+}
+
+func TestDeleteVersion(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	book := newTestBook("Dune")
+	if err := models.Books.Insert(ctx, book); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		id      int64
+		version uuid.UUID
+		want    error
+	}{
+		{"missing book", book.ID + 1, book.Version, ErrRecordNotFound},
+		{"stale version", book.ID, uuid.New(), ErrEditConflict},
+		{"current version", book.ID, book.Version, nil},
+		{"already deleted", book.ID, book.Version, ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := models.Books.DeleteVersion(ctx, tt.id, tt.version)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got error %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"mauk14.library/internal/validator"
+	"regexp"
+	"time"
+)
+
+var ErrDuplicateBarcode = errors.New("duplicate barcode")
+
+// Copy statuses. Only available copies can be lent out.
+const (
+	CopyAvailable = "available"
+	CopyOnLoan    = "on_loan"
+	CopyLost      = "lost"
+	CopyInRepair  = "in_repair"
+)
+
+var CopyStatuses = []string{CopyAvailable, CopyOnLoan, CopyLost, CopyInRepair}
+
+var CopyConditions = []string{"new", "good", "fair", "poor", "damaged"}
+
+// BarcodeRX matches the barcodes printed on copies: letters, digits and
+// hyphens, starting with a letter or digit.
+var BarcodeRX = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// Copy is one physical item of a book held by the library.
+type Copy struct {
+	ID        int64     `json:"id" bson:"id"`
+	BookID    int64     `json:"book_id" bson:"book_id"`
+	CreatedAt time.Time `json:"-" bson:"created_at"`
+	Barcode   string    `json:"barcode" bson:"barcode"`
+	Branch    string    `json:"branch" bson:"branch"`
+	Shelf     string    `json:"shelf,omitempty" bson:"shelf"`
+	Condition string    `json:"condition" bson:"condition"`
+	Status    string    `json:"status" bson:"status"`
+	Version   uuid.UUID `json:"version" bson:"version"`
+}
+
+// Availability counts the copies of a book by status.
+type Availability struct {
+	Total     int `json:"total"`
+	Available int `json:"available"`
+	OnLoan    int `json:"on_loan"`
+	Lost      int `json:"lost"`
+	InRepair  int `json:"in_repair"`
+}
+
+func (a *Availability) add(status string, n int) {
+	a.Total += n
+	switch status {
+	case CopyAvailable:
+		a.Available += n
+	case CopyOnLoan:
+		a.OnLoan += n
+	case CopyLost:
+		a.Lost += n
+	case CopyInRepair:
+		a.InRepair += n
+	}
+}
+
+// CopyFilter limits a listing of copies to one book, and optionally to a
+// status and a branch.
+type CopyFilter struct {
+	BookID int64
+	Status string
+	Branch string
+}
+
+type CopyModel struct {
+	Store   CopyStore
+	Timeout time.Duration
+}
+
+func (m *CopyModel) Insert(ctx context.Context, c *Copy) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	c.Version = uuid.New()
+	c.CreatedAt = time.Now()
+
+	return m.Store.Insert(ctx, c)
+}
+
+func (m *CopyModel) Get(ctx context.Context, id int64) (*Copy, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	return m.Store.Get(ctx, id)
+}
+
+func (m *CopyModel) GetAll(ctx context.Context, filter CopyFilter, filters Filters) ([]*Copy, Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.GetAll(ctx, filter, filters)
+}
+
+func (m *CopyModel) Update(ctx context.Context, c *Copy) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.Update(ctx, c)
+}
+
+func (m *CopyModel) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	return m.Store.Delete(ctx, id)
+}
+
+func (m *CopyModel) DeleteVersion(ctx context.Context, id int64, version uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	return m.Store.DeleteVersion(ctx, id, version)
+}
+
+// CountForBooks returns the availability of each of the books. Books without
+// copies are left out.
+func (m *CopyModel) CountForBooks(ctx context.Context, bookIDs []int64) (map[int64]Availability, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.CountForBooks(ctx, bookIDs)
+}
+
+func ValidateCopy(v *validator.Validator, c *Copy) {
+	v.Check(c.Barcode != "", "barcode", "must be provided")
+	v.Check(len(c.Barcode) <= 64, "barcode", "must not be more than 64 bytes long")
+	v.Check(c.Barcode == "" || validator.Matches(c.Barcode, BarcodeRX), "barcode", "must contain only letters, digits and hyphens")
+
+	v.Check(c.Branch != "", "branch", "must be provided")
+	v.Check(len(c.Branch) <= 200, "branch", "must not be more than 200 bytes long")
+
+	v.Check(len(c.Shelf) <= 200, "shelf", "must not be more than 200 bytes long")
+
+	v.Check(validator.PermittedValue(c.Condition, CopyConditions...), "condition", "must be new, good, fair, poor or damaged")
+	v.Check(validator.PermittedValue(c.Status, CopyStatuses...), "status", "must be available, on_loan, lost or in_repair")
+}
+
+func ValidateCopyFilter(v *validator.Validator, f CopyFilter) {
+	v.Check(f.Status == "" || validator.PermittedValue(f.Status, CopyStatuses...), "status", "must be available, on_loan, lost or in_repair")
+	v.Check(len(f.Branch) <= 200, "branch", "must not be more than 200 bytes long")
+}
@@ -0,0 +1,159 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestCopy(bookID int64, barcode string) *Copy {
+	return &Copy{BookID: bookID, Barcode: barcode, Branch: "Main", Condition: "good", Status: CopyAvailable}
+}
+
+func TestCopyBarcodes(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	book := newTestBook("Dune")
+	if err := models.Books.Insert(ctx, book); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, barcode := range []string{"DUNE-1", "DUNE-2"} {
+		if err := models.Copies.Insert(ctx, newTestCopy(book.ID, barcode)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// update loads the copy with the given id and applies change to it.
+	update := func(id int64, change func(c *Copy)) error {
+		c, err := models.Copies.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		change(c)
+		return models.Copies.Update(ctx, c)
+	}
+
+	tests := []struct {
+		name  string
+		write func() error
+		want  error
+	}{
+		{"insert a new barcode", func() error { return models.Copies.Insert(ctx, newTestCopy(book.ID, "DUNE-3")) }, nil},
+		{"insert a taken barcode", func() error { return models.Copies.Insert(ctx, newTestCopy(book.ID, "DUNE-1")) }, ErrDuplicateBarcode},
+		{"take another copy's barcode", func() error { return update(2, func(c *Copy) { c.Barcode = "DUNE-1" }) }, ErrDuplicateBarcode},
+		{"keep the copy's own barcode", func() error { return update(1, func(c *Copy) { c.Shelf = "A1" }) }, nil},
+		{"insert for a missing book", func() error { return models.Copies.Insert(ctx, newTestCopy(book.ID+1, "DUNE-4")) }, ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(); !errors.Is(err, tt.want) {
+				t.Errorf("got error %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestCopiesLeaveBookVersion checks that copies change the availability of
+// their book but not its version, which guards edits of the book itself.
+func TestCopiesLeaveBookVersion(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	book := newTestBook("Dune")
+	if err := models.Books.Insert(ctx, book); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCopy(book.ID, "DUNE-1")
+
+	steps := []struct {
+		name  string
+		write func() error
+		want  Availability
+	}{
+		{"insert", func() error { return models.Copies.Insert(ctx, c) }, Availability{Total: 1, Available: 1}},
+		{"lend", func() error { c.Status = CopyOnLoan; return models.Copies.Update(ctx, c) }, Availability{Total: 1, OnLoan: 1}},
+		{"repair", func() error { c.Status = CopyInRepair; return models.Copies.Update(ctx, c) }, Availability{Total: 1, InRepair: 1}},
+		{"delete", func() error { return models.Copies.DeleteVersion(ctx, c.ID, c.Version) }, Availability{}},
+	}
+
+	for _, step := range steps {
+		if err := step.write(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		counts, err := models.Copies.CountForBooks(ctx, []int64{book.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := counts[book.ID]; !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: got availability %+v; want %+v", step.name, got, step.want)
+		}
+
+		got, err := models.Books.Get(ctx, book.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != book.Version {
+			t.Errorf("%s: got book version %s; want %s", step.name, got.Version, book.Version)
+		}
+	}
+}
+
+func TestDeleteBookDeletesCopies(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	books := []*Book{newTestBook("Dune"), newTestBook("Emma")}
+	copies := []*Copy{newTestCopy(1, "DUNE-1"), newTestCopy(1, "DUNE-2"), newTestCopy(2, "EMMA-1")}
+
+	for _, book := range books {
+		if err := models.Books.Insert(ctx, book); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range copies {
+		if err := models.Copies.Insert(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := models.Books.Delete(ctx, books[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The copies of the deleted book are gone however they are reached,
+	// and the other book keeps its own.
+	tests := []struct {
+		name string
+		op   func() error
+		want error
+	}{
+		{"get", func() error { _, err := models.Copies.Get(ctx, copies[0].ID); return err }, ErrRecordNotFound},
+		{"update", func() error { return models.Copies.Update(ctx, copies[1]) }, ErrEditConflict},
+		{"delete", func() error { return models.Copies.Delete(ctx, copies[0].ID) }, ErrRecordNotFound},
+		{"delete version", func() error { return models.Copies.DeleteVersion(ctx, copies[1].ID, copies[1].Version) }, ErrRecordNotFound},
+		{"other book's copy", func() error { _, err := models.Copies.Get(ctx, copies[2].ID); return err }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); !errors.Is(err, tt.want) {
+				t.Errorf("got error %v; want %v", err, tt.want)
+			}
+		})
+	}
+
+	counts, err := models.Copies.CountForBooks(ctx, []int64{books[0].ID, books[1].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int64]Availability{books[1].ID: {Total: 1, Available: 1}}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("got availability %+v; want %+v", counts, want)
+	}
+}
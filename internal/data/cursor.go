@@ -0,0 +1,213 @@
+package data
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the decoded form of the opaque pagination token handed to
+// clients. It records the sort it was issued for and the values of the sort
+// keys, ending with the id, of the book it points at. Before is set when the
+// cursor walks backwards, so the page holds the books preceding that book
+// rather than following it.
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+	Before bool   `json:"b,omitempty"`
+}
+
+func newCursor(book *Book, filters Filters, before bool) string {
+	c := cursor{
+		Sort:   filters.Sort,
+		Before: before,
+	}
+	for _, key := range filters.sortKeys() {
+		c.Values = append(c.Values, bookSortValue(book, key.column))
+	}
+
+	js, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(token string, sort string, keys []sortKey) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var c cursor
+	if err := dec.Decode(&c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if c.Sort != sort || len(c.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	for i, key := range keys {
+		value, ok := decodeSortValue(key.column, c.Values[i])
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		c.Values[i] = value
+	}
+
+	return &c, nil
+}
+
+// decodeSortValue converts a sort value read back from JSON to the type
+// bookSortValue returns for column.
+func decodeSortValue(column string, value any) (any, bool) {
+	switch column {
+	case "title", "author":
+		s, ok := value.(string)
+		return s, ok
+	case "created_at":
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		return t, err == nil
+	case "relevance":
+		n, ok := value.(json.Number)
+		if !ok {
+			return nil, false
+		}
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		n, ok := value.(json.Number)
+		if !ok {
+			return nil, false
+		}
+		i, err := n.Int64()
+		return i, err == nil
+	}
+}
+
+// bookSortValue returns the value of book that column sorts on, as a string,
+// an int64, a time.Time or, for relevance, a float64.
+func bookSortValue(book *Book, column string) any {
+	switch column {
+	case "title":
+		return book.Title
+	case "author":
+		return book.Author
+	case "year":
+		return int64(book.Year)
+	case "size":
+		return int64(book.Size)
+	case "created_at":
+		return book.CreatedAt
+	case "relevance":
+		return book.Score
+	default:
+		return book.ID
+	}
+}
+
+func compareSortValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		s := b.(string)
+		switch {
+		case a < s:
+			return -1
+		case a > s:
+			return 1
+		default:
+			return 0
+		}
+	case float64:
+		f := b.(float64)
+		switch {
+		case a < f:
+			return -1
+		case a > f:
+			return 1
+		default:
+			return 0
+		}
+	case time.Time:
+		t := b.(time.Time)
+		switch {
+		case a.Before(t):
+			return -1
+		case a.After(t):
+			return 1
+		default:
+			return 0
+		}
+	default:
+		return compareInts(a.(int64), b.(int64))
+	}
+}
+
+// paginateBooks finishes a listing fetched by a store. In page mode books is
+// the requested page. In cursor mode, or without a count, it holds up to
+// limit+1 books in the order of travel, the extra one only telling whether
+// more books follow.
+func paginateBooks(books []*Book, totalRecords int, filters Filters) ([]*Book, Metadata, error) {
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if c == nil && !filters.SkipCount {
+		metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+		if len(books) > 0 && !filters.sortsBy("relevance") {
+			if filters.offset()+len(books) < totalRecords {
+				metadata.NextCursor = newCursor(books[len(books)-1], filters, false)
+			}
+			if filters.Page > 1 {
+				metadata.PrevCursor = newCursor(books[0], filters, true)
+			}
+		}
+		return books, metadata, nil
+	}
+
+	more := len(books) > filters.limit()
+	if more {
+		books = books[:filters.limit()]
+	}
+
+	before := c != nil && c.Before
+	if before {
+		for i, j := 0, len(books)-1; i < j; i, j = i+1, j-1 {
+			books[i], books[j] = books[j], books[i]
+		}
+	}
+
+	metadata := Metadata{PageSize: filters.PageSize}
+
+	if !filters.SkipCount {
+		if totalRecords == 0 {
+			return books, Metadata{}, nil
+		}
+		metadata.TotalRecords = totalRecords
+	}
+
+	if len(books) > 0 {
+		if more || before {
+			metadata.NextCursor = newCursor(books[len(books)-1], filters, false)
+		}
+		if c != nil && (more || !before) || c == nil && filters.Page > 1 {
+			metadata.PrevCursor = newCursor(books[0], filters, true)
+		}
+	}
+
+	return books, metadata, nil
+}
@@ -0,0 +1,189 @@
+package data
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"mauk14.library/internal/validator"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var cursorSortSafelist = []string{"id", "title", "author", "year", "size", "created_at", "-id", "-title", "-author", "-year", "-size", "-created_at"}
+
+func TestCursorRoundTrip(t *testing.T) {
+	book := &Book{
+		ID:        42,
+		CreatedAt: time.Date(2024, 3, 1, 12, 30, 15, 123456789, time.UTC),
+		Title:     "Dune",
+		Author:    "Frank Herbert",
+		Year:      1965,
+		Size:      412,
+	}
+
+	tests := []struct {
+		name   string
+		sort   string
+		before bool
+		want   []any
+	}{
+		{"id", "id", false, []any{int64(42)}},
+		{"descending year", "-year", false, []any{int64(1965), int64(42)}},
+		{"title and created_at", "title,-created_at", true, []any{"Dune", book.CreatedAt, int64(42)}},
+		{"author and size", "author,size", false, []any{"Frank Herbert", int64(412), int64(42)}},
+		{"keys after id", "-id,title", true, []any{int64(42)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := Filters{Sort: tt.sort, SortSafelist: cursorSortSafelist}
+
+			token := newCursor(book, filters, tt.before)
+
+			c, err := decodeCursor(token, tt.sort, filters.sortKeys())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(c.Values, tt.want) {
+				t.Errorf("got values %#v; want %#v", c.Values, tt.want)
+			}
+			if c.Before != tt.before {
+				t.Errorf("got before %t; want %t", c.Before, tt.before)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	encode := func(js string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(js))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		sort  string
+	}{
+		{"not base64", "not a cursor!", "id"},
+		{"not JSON", encode("id=1"), "id"},
+		{"other sort", encode(`{"s":"year","v":[1965,1]}`), "-year"},
+		{"missing values", encode(`{"s":"year","v":[1965]}`), "year"},
+		{"extra values", encode(`{"s":"id","v":[1,2]}`), "id"},
+		{"string id", encode(`{"s":"id","v":["1"]}`), "id"},
+		{"fractional id", encode(`{"s":"id","v":[1.5]}`), "id"},
+		{"numeric title", encode(`{"s":"title","v":[7,1]}`), "title"},
+		{"bad time", encode(`{"s":"created_at","v":["yesterday",1]}`), "created_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := Filters{Sort: tt.sort, SortSafelist: cursorSortSafelist}
+
+			_, err := decodeCursor(tt.token, tt.sort, filters.sortKeys())
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("got error %v; want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestCursorPaging(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	for _, book := range filterTestBooks {
+		book := *book
+		if err := models.Books.Insert(ctx, &book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// By descending year the books are 5, 2, 1, 4 and 3.
+	filters := Filters{Page: 1, PageSize: 2, Sort: "-year", SortSafelist: cursorSortSafelist}
+
+	// Each step follows the next or the previous cursor of the step before,
+	// as thenNext of that step says.
+	steps := []struct {
+		name     string
+		thenNext bool
+		want     []int64
+	}{
+		{"first page", true, []int64{5, 2}},
+		{"forwards", true, []int64{1, 4}},
+		{"last page", false, []int64{3}},
+		{"backwards", false, []int64{1, 4}},
+		{"back to the start", true, []int64{5, 2}},
+	}
+
+	var metadata Metadata
+
+	for i, step := range steps {
+		if i > 0 {
+			filters.Cursor = metadata.NextCursor
+			if !steps[i-1].thenNext {
+				filters.Cursor = metadata.PrevCursor
+			}
+		}
+
+		var books []*Book
+		var err error
+
+		books, metadata, err = models.Books.GetAll(ctx, BookFilter{}, filters)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		got := make([]int64, len(books))
+		for j, book := range books {
+			got[j] = book.ID
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: got books %v; want %v", step.name, got, step.want)
+		}
+	}
+
+	if metadata.PrevCursor != "" {
+		t.Errorf("got a previous cursor before the first page")
+	}
+}
+
+func TestValidateFiltersCursor(t *testing.T) {
+	book := newTestBook("Dune")
+	book.ID = 1
+
+	safelist := append([]string{"relevance"}, cursorSortSafelist...)
+	byTitle := newCursor(book, Filters{Sort: "title", SortSafelist: safelist}, false)
+
+	tests := []struct {
+		name    string
+		filters Filters
+		want    map[string]string
+	}{
+		{"valid", Filters{Page: 1, PageSize: 20, Sort: "title", Cursor: byTitle}, nil},
+		{"with page", Filters{Page: 2, PageSize: 20, Sort: "title", Cursor: byTitle}, map[string]string{"cursor": "must not be combined with page"}},
+		{"other sort", Filters{Page: 1, PageSize: 20, Sort: "-title", Cursor: byTitle}, map[string]string{"cursor": "must be a cursor returned for the same sort"}},
+		{"garbage", Filters{Page: 1, PageSize: 20, Sort: "title", Cursor: "garbage"}, map[string]string{"cursor": "must be a cursor returned for the same sort"}},
+		{"relevance", Filters{Page: 1, PageSize: 20, Sort: "relevance", Cursor: byTitle}, map[string]string{"cursor": "is not supported when sorting by relevance"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filters.SortSafelist = safelist
+
+			v := validator.New()
+			ValidateFilters(v, tt.filters)
+
+			if len(tt.want) == 0 {
+				if !v.Valid() {
+					t.Errorf("got errors %v; want none", v.Errors)
+				}
+				return
+			}
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("got errors %v; want %v", v.Errors, tt.want)
+			}
+		})
+	}
+}
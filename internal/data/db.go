@@ -2,13 +2,81 @@ package data
 
 import (
 	"context"
+	"github.com/google/uuid"
+	"time"
 )
 
+// DB is implemented by every storage backend. It hands out one store per
+// aggregate, so models never deal with collection names or untyped payloads.
 type DB interface {
-	Insert(ctx context.Context, query string, collection string, data interface{}) error
-	Get(ctx context.Context, query string, id interface{}, collection string, scope string) (interface{}, error)
-	GetAll(ctx context.Context, query string, collection string, opt map[string]string, genres []string, filters Filters) ([]*Book, Metadata, error)
-	Update(ctx context.Context, query string, data interface{}) error
-	Delete(ctx context.Context, query string, id int64, collection string, scope string) error
-	GetLastId(ctx context.Context, query string, collection string) (int64, error)
+	Books() BookStore
+	Authors() AuthorStore
+	Copies() CopyStore
+	Users() UserStore
+	Tokens() TokenStore
+	Permissions() PermissionStore
+
+	// WithTx runs fn in a single transaction. The DB handed to fn is bound
+	// to that transaction: if fn returns an error, none of its writes are kept.
+	WithTx(ctx context.Context, fn func(ctx context.Context, tx DB) error) error
+}
+
+// BookStore and UserStore assign the ID of a new record themselves, so
+// concurrent inserts never race for the same value.
+type BookStore interface {
+	Insert(ctx context.Context, book *Book) error
+	Get(ctx context.Context, id int64, fields ...string) (*Book, error)
+	GetByISBN(ctx context.Context, isbn13 string) (*Book, error)
+	GetAll(ctx context.Context, filter BookFilter, filters Filters) ([]*Book, Metadata, error)
+	Facets(ctx context.Context, filter BookFilter, fields []string) (Facets, error)
+	Suggest(ctx context.Context, field string, prefix string, limit int) ([]Suggestion, error)
+	Update(ctx context.Context, book *Book) error
+	Delete(ctx context.Context, id int64) error
+	// DeleteVersion deletes the book only if it still has the given
+	// version. It returns ErrRecordNotFound if the book does not exist and
+	// ErrEditConflict if it has another version.
+	DeleteVersion(ctx context.Context, id int64, version uuid.UUID) error
+}
+
+type AuthorStore interface {
+	Insert(ctx context.Context, author *Author) error
+	Get(ctx context.Context, id int64) (*Author, error)
+	GetAll(ctx context.Context, name string, filters Filters) ([]*Author, Metadata, error)
+	Update(ctx context.Context, author *Author) error
+	Delete(ctx context.Context, id int64) error
+	GetForBooks(ctx context.Context, bookIDs []int64) (map[int64][]BookAuthor, error)
+	SetForBook(ctx context.Context, bookID int64, authors []BookAuthor) error
+	LinkByName(ctx context.Context, bookID int64, name string) error
+	ExistsByName(ctx context.Context, name string) (bool, error)
+}
+
+type CopyStore interface {
+	Insert(ctx context.Context, c *Copy) error
+	Get(ctx context.Context, id int64) (*Copy, error)
+	GetAll(ctx context.Context, filter CopyFilter, filters Filters) ([]*Copy, Metadata, error)
+	Update(ctx context.Context, c *Copy) error
+	Delete(ctx context.Context, id int64) error
+	// DeleteVersion deletes the copy only if it still has the given
+	// version. It returns ErrRecordNotFound if the copy does not exist and
+	// ErrEditConflict if it has another version.
+	DeleteVersion(ctx context.Context, id int64, version uuid.UUID) error
+	CountForBooks(ctx context.Context, bookIDs []int64) (map[int64]Availability, error)
+}
+
+type UserStore interface {
+	Insert(ctx context.Context, user *User) error
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetForToken(ctx context.Context, scope string, hash []byte, now time.Time) (*User, error)
+	Update(ctx context.Context, user *User) error
+}
+
+type TokenStore interface {
+	Insert(ctx context.Context, token *Token) error
+	DeleteAllForUser(ctx context.Context, scope string, userID int64) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type PermissionStore interface {
+	GetAllForUser(ctx context.Context, userID int64) (Permissions, error)
+	AddForUser(ctx context.Context, userID int64, codes ...string) error
 }
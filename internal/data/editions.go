@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A work is the group of editions of one title, such as its hardcover,
+// paperback and audiobook. Every book is an edition and carries the WorkID
+// of its work, which is the ID of the work's first edition; when that edition
+// is deleted, the stores move the work to the lowest remaining edition ID.
+
+// Edition formats. Audiobooks have a Duration instead of a Size.
+const (
+	FormatHardcover = "hardcover"
+	FormatPaperback = "paperback"
+	FormatEbook     = "ebook"
+	FormatAudiobook = "audiobook"
+)
+
+var EditionFormats = []string{FormatHardcover, FormatPaperback, FormatEbook, FormatAudiobook}
+
+var ErrInvalidDurationFormat = errors.New("invalid duration format")
+
+// ErrUnknownWork is returned when a book joins a work that has no editions.
+var ErrUnknownWork = errors.New("unknown work")
+
+// Duration is the running time of an audiobook in minutes.
+type Duration int32
+
+// String renders the duration the way it appears in JSON, e.g. "540 minutes".
+func (d Duration) String() string {
+	return fmt.Sprintf("%d minutes", d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+func (d *Duration) UnmarshalJSON(jsonValue []byte) error {
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidDurationFormat
+	}
+
+	parts := strings.Split(unquotedJSONValue, " ")
+
+	if len(parts) != 2 || parts[1] != "minutes" {
+		return ErrInvalidDurationFormat
+	}
+
+	i, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return ErrInvalidDurationFormat
+	}
+
+	*d = Duration(i)
+	return nil
+}
+
+// WorkExists reports whether a work with the given ID has any editions.
+func (m *BookModel) WorkExists(ctx context.Context, workID int64) (bool, error) {
+	if workID < 1 {
+		return false, nil
+	}
+
+	filters := Filters{Page: 1, PageSize: 1, Sort: "id", SortSafelist: []string{"id"}, SkipCount: true}
+
+	books, _, err := m.GetAll(ctx, BookFilter{WorkIDs: []int64{workID}}, filters)
+	if err != nil {
+		return false, err
+	}
+
+	return len(books) > 0, nil
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeleteEditionMovesWork(t *testing.T) {
+	tests := []struct {
+		name      string
+		delete    int
+		wantWork  int
+		wantMoved bool
+	}{
+		{"later edition", 2, 0, false},
+		{"first edition", 0, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			models := NewModels(NewMemory(), time.Second)
+
+			// Three editions of one work, whose first edition is the first
+			// book.
+			editions := make([]*Book, 3)
+			for i := range editions {
+				editions[i] = newTestBook("Dune")
+				if i > 0 {
+					editions[i].WorkID = editions[0].ID
+				}
+				if err := models.Books.Insert(ctx, editions[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := models.Books.Delete(ctx, editions[tt.delete].ID); err != nil {
+				t.Fatal(err)
+			}
+
+			wantWork := editions[tt.wantWork].ID
+
+			for i, edition := range editions {
+				if i == tt.delete {
+					continue
+				}
+
+				got, err := models.Books.Get(ctx, edition.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got.WorkID != wantWork {
+					t.Errorf("edition %d: got work_id %d; want %d", edition.ID, got.WorkID, wantWork)
+				}
+				if moved := got.Version != edition.Version; moved != tt.wantMoved {
+					t.Errorf("edition %d: got new version %t; want %t", edition.ID, moved, tt.wantMoved)
+				}
+			}
+		})
+	}
+}
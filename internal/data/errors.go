@@ -0,0 +1,66 @@
+package data
+
+import (
+	"errors"
+)
+
+// Backends translate driver errors into these values, so callers can
+// handle them the same way whichever database is in use.
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	ErrEditConflict   = errors.New("edit conflict")
+	ErrDuplicateKey   = errors.New("duplicate key")
+	ErrConflict       = errors.New("conflicting concurrent write")
+	ErrTimeout        = errors.New("database operation timed out")
+	ErrUnavailable    = errors.New("database unavailable")
+)
+
+// DuplicateKeyError reports a write that violated the unique index on
+// Field. It matches ErrDuplicateKey, ErrDuplicateEmail when Field is
+// "email", ErrDuplicateISBN when Field is "isbn_13" and ErrDuplicateBarcode
+// when Field is "barcode".
+type DuplicateKeyError struct {
+	Field string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return "duplicate key on field " + e.Field
+}
+
+func (e *DuplicateKeyError) Is(target error) bool {
+	switch target {
+	case ErrDuplicateKey:
+		return true
+	case ErrDuplicateEmail:
+		return e.Field == "email"
+	case ErrDuplicateISBN:
+		return e.Field == "isbn_13"
+	case ErrDuplicateBarcode:
+		return e.Field == "barcode"
+	default:
+		return false
+	}
+}
+
+// dbError pairs one of the errors above with the driver error behind it,
+// which stays reachable through errors.As for logging and retry decisions.
+type dbError struct {
+	kind error
+	err  error
+}
+
+func wrapError(kind error, err error) error {
+	return &dbError{kind: kind, err: err}
+}
+
+func (e *dbError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e *dbError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *dbError) Unwrap() error {
+	return e.err
+}
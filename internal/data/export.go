@@ -0,0 +1,36 @@
+package data
+
+import (
+	"context"
+)
+
+// exportBatchSize is the number of books Walk loads per query.
+const exportBatchSize = 500
+
+// Walk calls fn for every book matching filter in the order given by the Sort
+// of filters, loading the books in batches through keyset cursors so the
+// whole result is never held in memory, and without counting them. Only Sort,
+// SortSafelist and Fields of filters are used, and the sort must not include
+// relevance, which cursors do not support. Walking stops at the first error
+// fn returns.
+func (m *BookModel) Walk(ctx context.Context, filter BookFilter, filters Filters, fn func(*Book) error) error {
+	filters.Page, filters.PageSize, filters.Cursor, filters.SkipCount = 1, exportBatchSize, "", true
+
+	for {
+		books, metadata, err := m.GetAll(ctx, filter, filters)
+		if err != nil {
+			return err
+		}
+
+		for _, book := range books {
+			if err := fn(book); err != nil {
+				return err
+			}
+		}
+
+		if metadata.NextCursor == "" {
+			return nil
+		}
+		filters.Cursor = metadata.NextCursor
+	}
+}
@@ -0,0 +1,99 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWalk(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	// More than two batches, the last one partial.
+	const n = 2*exportBatchSize + 1
+
+	for i := 0; i < n; i++ {
+		if err := models.Books.Insert(ctx, newTestBook("Dune")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		sort      string
+		wantFirst int64
+		wantStep  int64
+	}{
+		{"ascending", "id", 1, 1},
+		{"descending", "-id", n, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := Filters{Sort: tt.sort, SortSafelist: []string{"id", "-id"}}
+
+			want := tt.wantFirst
+			err := models.Books.Walk(ctx, BookFilter{}, filters, func(book *Book) error {
+				if book.ID != want {
+					t.Fatalf("got book %d; want %d", book.ID, want)
+				}
+				want += tt.wantStep
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := (want - tt.wantFirst) * tt.wantStep; got != n {
+				t.Errorf("walked %d books; want %d", got, n)
+			}
+		})
+	}
+}
+
+func TestSkipCount(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	for _, book := range filterTestBooks {
+		book := *book
+		if err := models.Books.Insert(ctx, &book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Following the cursors of a listing without a count visits every book
+	// once, with no totals in the metadata.
+	filters := Filters{Page: 1, PageSize: 2, Sort: "id", SortSafelist: []string{"id"}, SkipCount: true}
+
+	wantPages := [][]int64{{1, 2}, {3, 4}, {5}}
+
+	for i, want := range wantPages {
+		books, metadata, err := models.Books.GetAll(ctx, BookFilter{}, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(books) != len(want) {
+			t.Fatalf("page %d: got %d books; want %d", i+1, len(books), len(want))
+		}
+		for j, book := range books {
+			if book.ID != want[j] {
+				t.Errorf("page %d: got book %d; want %d", i+1, book.ID, want[j])
+			}
+		}
+
+		if metadata.TotalRecords != 0 || metadata.LastPage != 0 {
+			t.Errorf("page %d: got totals in %+v; want none", i+1, metadata)
+		}
+		if last := i == len(wantPages)-1; last != (metadata.NextCursor == "") {
+			t.Errorf("page %d: got next cursor %q", i+1, metadata.NextCursor)
+		}
+		if first := i == 0; first != (metadata.PrevCursor == "") {
+			t.Errorf("page %d: got previous cursor %q", i+1, metadata.PrevCursor)
+		}
+
+		filters.Cursor = metadata.NextCursor
+	}
+}
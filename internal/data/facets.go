@@ -0,0 +1,41 @@
+package data
+
+import (
+	"context"
+	"mauk14.library/internal/validator"
+)
+
+// FacetSafelist lists the book fields that facets can be computed for.
+var FacetSafelist = []string{"genres", "year", "author"}
+
+// facetLimit caps the number of values returned for the genres and author
+// facets, which keep the most frequent ones. The year facet is a histogram
+// and returns every year.
+const facetLimit = 20
+
+type FacetCount struct {
+	Value any `json:"value"`
+	Count int `json:"count"`
+}
+
+// Facets maps each requested field to the number of matching books per
+// value of that field.
+type Facets map[string][]FacetCount
+
+func (m *BookModel) Facets(ctx context.Context, filter BookFilter, fields []string) (Facets, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return m.Store.Facets(ctx, filter, fields)
+}
+
+func ValidateFacets(v *validator.Validator, fields []string) {
+	for _, field := range fields {
+		v.Check(validator.PermittedValue(field, FacetSafelist...), "facets", "invalid facet value")
+	}
+	v.Check(validator.Unique(fields), "facets", "must not contain duplicate values")
+}
@@ -0,0 +1,134 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"mauk14.library/internal/validator"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFacets(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	for _, book := range filterTestBooks {
+		book := *book
+		if err := models.Books.Insert(ctx, &book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter BookFilter
+		fields []string
+		want   Facets
+	}{
+		{
+			name:   "genres by count, then by value",
+			fields: []string{"genres"},
+			want: Facets{"genres": {
+				{"classic", 3}, {"fantasy", 2}, {"science fiction", 2}, {"romance", 1},
+			}},
+		},
+		{
+			name:   "years in order",
+			fields: []string{"year"},
+			want: Facets{"year": {
+				{int64(1815), 1}, {int64(1937), 1}, {int64(1965), 1}, {int64(1969), 1}, {int64(2001), 1},
+			}},
+		},
+		{
+			name:   "filtered",
+			filter: BookFilter{Title: "dune"},
+			fields: []string{"genres", "author"},
+			want: Facets{
+				"genres": {{"science fiction", 2}, {"classic", 1}, {"fantasy", 1}},
+				"author": {{"Frank Herbert", 2}, {"Anonymous", 1}},
+			},
+		},
+		{
+			name:   "no matches",
+			filter: BookFilter{Genres: []string{"horror"}},
+			fields: []string{"year"},
+			want:   Facets{"year": {}},
+		},
+		{
+			name:   "no fields",
+			fields: nil,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := models.Books.Facets(ctx, tt.filter, tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got facets %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFacetLimit(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	// Every book has its own author and year, so both facets have more
+	// values than the limit.
+	for i := 0; i < facetLimit+5; i++ {
+		book := newTestBook("Dune")
+		book.Author = fmt.Sprintf("Author %02d", i)
+		book.Year = int32(1900 + i)
+		if err := models.Books.Insert(ctx, book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	facets, err := models.Books.Facets(ctx, BookFilter{}, []string{"author", "year"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(facets["author"]); n != facetLimit {
+		t.Errorf("got %d authors; want %d", n, facetLimit)
+	}
+	if n := len(facets["year"]); n != facetLimit+5 {
+		t.Errorf("got %d years; want all %d", n, facetLimit+5)
+	}
+}
+
+func TestValidateFacets(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		want   map[string]string
+	}{
+		{"none", nil, nil},
+		{"all", []string{"genres", "year", "author"}, nil},
+		{"unknown", []string{"genres", "publisher"}, map[string]string{"facets": "invalid facet value"}},
+		{"duplicate", []string{"year", "year"}, map[string]string{"facets": "must not contain duplicate values"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateFacets(v, tt.fields)
+
+			if len(tt.want) == 0 {
+				if !v.Valid() {
+					t.Errorf("got errors %v; want none", v.Errors)
+				}
+				return
+			}
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("got errors %v; want %v", v.Errors, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package data
+
+import (
+	"mauk14.library/internal/validator"
+)
+
+// BookFieldSafelist lists the book fields clients can select with fields=.
+var BookFieldSafelist = []string{"id", "title", "author", "year", "genres", "isbn_10", "isbn_13", "work_id", "format", "duration", "publisher", "version", "score"}
+
+func ValidateFields(v *validator.Validator, fields []string, safelist []string) {
+	for _, field := range fields {
+		v.Check(validator.PermittedValue(field, safelist...), "fields", "invalid fields value")
+	}
+	v.Check(validator.Unique(fields), "fields", "must not contain duplicate values")
+}
+
+// bookProjection returns the stored fields a store has to load to serve a
+// response limited to fields. The id, work_id and version are always loaded,
+// as are any extra fields the store needs itself, such as the sort column for
+// cursors. The work_id lets editions be grouped by work. A nil result means
+// every field.
+func bookProjection(fields []string, extra ...string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	projection := []string{"id", "work_id", "version"}
+	for _, field := range append(fields, extra...) {
+		switch field {
+		case "id", "work_id", "version", "score", "relevance":
+			continue
+		}
+		if !validator.PermittedValue(field, projection...) {
+			projection = append(projection, field)
+		}
+	}
+
+	return projection
+}
+
+func selectsField(projection []string, field string) bool {
+	return projection == nil || validator.PermittedValue(field, projection...)
+}
@@ -0,0 +1,29 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBookProjection(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		extra  []string
+		want   []string
+	}{
+		{"every field", nil, []string{"title"}, nil},
+		{"always loaded", []string{"title"}, nil, []string{"id", "work_id", "version", "title"}},
+		{"sort columns", []string{"title"}, []string{"year", "id"}, []string{"id", "work_id", "version", "title", "year"}},
+		{"computed", []string{"score", "author"}, []string{"relevance"}, []string{"id", "work_id", "version", "author"}},
+		{"no duplicates", []string{"year", "title"}, []string{"title", "year"}, []string{"id", "work_id", "version", "year", "title"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bookProjection(tt.fields, tt.extra...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got projection %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
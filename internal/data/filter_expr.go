@@ -0,0 +1,141 @@
+package data
+
+import "time"
+
+// FilterExpr is a node of a parsed book filter expression. It is one of
+// FilterAnd, FilterOr, FilterNot or FilterCondition, and each DB
+// implementation compiles it into its own query language.
+type FilterExpr interface {
+	filterExpr()
+}
+
+type FilterAnd struct {
+	Exprs []FilterExpr
+}
+
+type FilterOr struct {
+	Exprs []FilterExpr
+}
+
+type FilterNot struct {
+	Expr FilterExpr
+}
+
+type FilterOp string
+
+const (
+	FilterEq  FilterOp = "="
+	FilterNe  FilterOp = "!="
+	FilterLt  FilterOp = "<"
+	FilterLte FilterOp = "<="
+	FilterGt  FilterOp = ">"
+	FilterGte FilterOp = ">="
+	FilterAny FilterOp = "any"
+	FilterAll FilterOp = "all"
+)
+
+// FilterCondition compares a book field against a value. The value is an
+// int64 for id, year and size, a time.Time for created_at, a string for
+// title and author, and a []string for genres, which only supports
+// FilterAny and FilterAll.
+type FilterCondition struct {
+	Field string
+	Op    FilterOp
+	Value any
+}
+
+func (FilterAnd) filterExpr()       {}
+func (FilterOr) filterExpr()        {}
+func (FilterNot) filterExpr()       {}
+func (FilterCondition) filterExpr() {}
+
+// FilterFields maps each filterable book field to the operators it accepts.
+var FilterFields = map[string][]FilterOp{
+	"id":         {FilterEq, FilterNe, FilterLt, FilterLte, FilterGt, FilterGte},
+	"year":       {FilterEq, FilterNe, FilterLt, FilterLte, FilterGt, FilterGte},
+	"size":       {FilterEq, FilterNe, FilterLt, FilterLte, FilterGt, FilterGte},
+	"created_at": {FilterEq, FilterNe, FilterLt, FilterLte, FilterGt, FilterGte},
+	"title":      {FilterEq, FilterNe},
+	"author":     {FilterEq, FilterNe},
+	"genres":     {FilterAny, FilterAll},
+}
+
+// matchFilterExpr evaluates e against book. It backs the memory store, and
+// defines the semantics the other stores translate.
+func matchFilterExpr(e FilterExpr, book *Book) bool {
+	switch e := e.(type) {
+	case FilterAnd:
+		for _, expr := range e.Exprs {
+			if !matchFilterExpr(expr, book) {
+				return false
+			}
+		}
+		return true
+	case FilterOr:
+		for _, expr := range e.Exprs {
+			if matchFilterExpr(expr, book) {
+				return true
+			}
+		}
+		return false
+	case FilterNot:
+		return !matchFilterExpr(e.Expr, book)
+	case FilterCondition:
+		return matchFilterCondition(e, book)
+	default:
+		panic("unknown filter expression")
+	}
+}
+
+func matchFilterCondition(c FilterCondition, book *Book) bool {
+	var cmp int
+
+	switch c.Field {
+	case "id":
+		cmp = compareInts(book.ID, c.Value.(int64))
+	case "year":
+		cmp = compareInts(int64(book.Year), c.Value.(int64))
+	case "size":
+		cmp = compareInts(int64(book.Size), c.Value.(int64))
+	case "created_at":
+		t := c.Value.(time.Time)
+		switch {
+		case book.CreatedAt.Before(t):
+			cmp = -1
+		case book.CreatedAt.After(t):
+			cmp = 1
+		}
+	case "title":
+		cmp = compareSortValues(book.Title, c.Value)
+	case "author":
+		cmp = compareSortValues(book.Author, c.Value)
+	case "genres":
+		genres := c.Value.([]string)
+		if c.Op == FilterAll {
+			return containsAll(book.Genres, genres)
+		}
+		for _, genre := range genres {
+			if containsAll(book.Genres, []string{genre}) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch c.Op {
+	case FilterEq:
+		return cmp == 0
+	case FilterNe:
+		return cmp != 0
+	case FilterLt:
+		return cmp < 0
+	case FilterLte:
+		return cmp <= 0
+	case FilterGt:
+		return cmp > 0
+	case FilterGte:
+		return cmp >= 0
+	default:
+		return false
+	}
+}
@@ -7,11 +7,13 @@ import (
 )
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
 }
 
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
@@ -32,27 +34,104 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	Cursor       string
+	Fields       []string
+
+	// SkipCount spares the stores counting the matching books, for callers
+	// that only follow cursors. The metadata then has no totals or page
+	// numbers, and NextCursor alone tells whether more books follow.
+	SkipCount bool
+}
+
+// sortKey is one component of the sort parameter, such as -year.
+type sortKey struct {
+	column     string
+	descending bool
+}
+
+func sortColumnOf(sort string) string {
+	return strings.TrimPrefix(sort, "-")
+}
+
+// sortKeys parses Sort, a comma-separated list of columns each optionally
+// prefixed with - for descending order. The keys always end with id, which
+// breaks ties; any keys after an explicit id are dropped as ids are unique.
+func (f Filters) sortKeys() []sortKey {
+	var keys []sortKey
+
+	for _, part := range strings.Split(f.Sort, ",") {
+		if !validator.PermittedValue(part, f.SortSafelist...) {
+			panic("unsafe sort parameter: " + f.Sort)
+		}
+
+		key := sortKey{
+			column:     sortColumnOf(part),
+			descending: part == "relevance" || strings.HasPrefix(part, "-"),
+		}
+		keys = append(keys, key)
+
+		if key.column == "id" {
+			return keys
+		}
+	}
+
+	return append(keys, sortKey{column: "id"})
+}
+
+// walkKeys returns the sort keys in the order the listing is walked, which is
+// reversed when paging backwards from a cursor.
+func (f Filters) walkKeys(c *cursor) []sortKey {
+	keys := f.sortKeys()
+	if c != nil && c.Before {
+		for i := range keys {
+			keys[i].descending = !keys[i].descending
+		}
+	}
+	return keys
 }
 
-func (f Filters) sortColumn() string {
-	for _, safeValue := range f.SortSafelist {
-		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-")
+// sortsBy reports whether column is one of the sort keys.
+func (f Filters) sortsBy(column string) bool {
+	for _, key := range f.sortKeys() {
+		if key.column == column {
+			return true
 		}
 	}
-	panic("unsafe sort parameter: " + f.Sort)
+	return false
+}
+
+func (f Filters) sortColumns() []string {
+	keys := f.sortKeys()
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = key.column
+	}
+	return columns
 }
 
-func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
+// cursor decodes the pagination cursor. It returns nil when the listing is
+// paginated by page number.
+func (f Filters) cursor() (*cursor, error) {
+	if f.Cursor == "" {
+		return nil, nil
 	}
-	return "ASC"
+	return decodeCursor(f.Cursor, f.Sort, f.sortKeys())
 }
 
 func (f Filters) limit() int {
 	return f.PageSize
 }
+
+// fetchLimit is the number of books a store fetches for the page. Without a
+// count, it is one past the page, the extra book only telling whether more
+// books follow.
+func (f Filters) fetchLimit(c *cursor) int {
+	if c != nil || f.SkipCount {
+		return f.limit() + 1
+	}
+	return f.limit()
+}
+
 func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
@@ -63,6 +142,29 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 
-	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	if ValidateSort(v, f) && f.Cursor != "" {
+		v.Check(f.Page == 1, "cursor", "must not be combined with page")
+		v.Check(!f.sortsBy("relevance"), "cursor", "is not supported when sorting by relevance")
+
+		_, err := f.cursor()
+		v.Check(err == nil, "cursor", "must be a cursor returned for the same sort")
+	}
+
+}
+
+// ValidateSort checks the Sort of f against its SortSafelist, reporting
+// whether every key is safe to hand to a store.
+func ValidateSort(v *validator.Validator, f Filters) bool {
+	parts := strings.Split(f.Sort, ",")
+	columns := make([]string, len(parts))
+	safe := true
+	for i, part := range parts {
+		safe = safe && validator.PermittedValue(part, f.SortSafelist...)
+		columns[i] = sortColumnOf(part)
+	}
+	v.Check(safe, "sort", "invalid sort value")
+	v.Check(len(parts) <= 5, "sort", "must not contain more than 5 keys")
+	v.Check(validator.Unique(columns), "sort", "must not sort by the same column twice")
 
+	return safe
 }
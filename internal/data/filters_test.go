@@ -0,0 +1,290 @@
+package data
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"mauk14.library/internal/validator"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// filterTestBooks are inserted in order, so their IDs start at 1.
+var filterTestBooks = []*Book{
+	{Title: "Dune", Author: "Frank Herbert", Year: 1965, Size: 412, Genres: []string{"science fiction", "classic"}},
+	{Title: "Dune Messiah", Author: "Frank Herbert", Year: 1969, Size: 256, Genres: []string{"science fiction"}},
+	{Title: "Emma", Author: "Jane Austen", Year: 1815, Size: 474, Genres: []string{"romance", "classic"}},
+	{Title: "The Hobbit (Illustrated)", Author: "J. R. R. Tolkien", Year: 1937, Size: 310, Genres: []string{"fantasy", "classic"}},
+	{Title: "dune", Author: "Anonymous", Year: 2001, Size: 12, Genres: []string{"fantasy"}},
+}
+
+// bookFilterTests describe each filter combination once, as the condition
+// the Postgres and MongoDB stores build for it and the books it selects. The
+// books are checked against the memory store, whose semantics the other two
+// translate.
+var bookFilterTests = []struct {
+	name      string
+	filter    BookFilter
+	wantSQL   string
+	wantArgs  []any
+	wantMongo bson.M
+	wantIDs   []int64
+}{
+	{
+		name:      "no filter",
+		filter:    BookFilter{},
+		wantSQL:   "TRUE",
+		wantMongo: bson.M{},
+		wantIDs:   []int64{1, 2, 3, 4, 5},
+	},
+	{
+		name:      "empty values",
+		filter:    BookFilter{Title: "", Author: "", Genres: []string{}},
+		wantSQL:   "TRUE",
+		wantMongo: bson.M{},
+		wantIDs:   []int64{1, 2, 3, 4, 5},
+	},
+	{
+		name:      "title",
+		filter:    BookFilter{Title: "dune"},
+		wantSQL:   "title ~* $1",
+		wantArgs:  []any{"dune"},
+		wantMongo: bson.M{"title": bson.M{"$regex": "dune", "$options": "i"}},
+		wantIDs:   []int64{1, 2, 5},
+	},
+	{
+		name:      "author",
+		filter:    BookFilter{Author: "HERBERT"},
+		wantSQL:   "author ~* $1",
+		wantArgs:  []any{"HERBERT"},
+		wantMongo: bson.M{"author": bson.M{"$regex": "HERBERT", "$options": "i"}},
+		wantIDs:   []int64{1, 2},
+	},
+	{
+		name:      "genres",
+		filter:    BookFilter{Genres: []string{"classic"}},
+		wantSQL:   "genres @> $1",
+		wantArgs:  []any{[]string{"classic"}},
+		wantMongo: bson.M{"genres": bson.M{"$all": []string{"classic"}}},
+		wantIDs:   []int64{1, 3, 4},
+	},
+	{
+		name:      "title and genres",
+		filter:    BookFilter{Title: "dune", Genres: []string{"fantasy"}},
+		wantSQL:   "title ~* $1 AND genres @> $2",
+		wantArgs:  []any{"dune", []string{"fantasy"}},
+		wantMongo: bson.M{"title": bson.M{"$regex": "dune", "$options": "i"}, "genres": bson.M{"$all": []string{"fantasy"}}},
+		wantIDs:   []int64{5},
+	},
+	{
+		name:     "title, author and genres",
+		filter:   BookFilter{Title: "dune", Author: "herbert", Genres: []string{"science fiction", "classic"}},
+		wantSQL:  "title ~* $1 AND author ~* $2 AND genres @> $3",
+		wantArgs: []any{"dune", "herbert", []string{"science fiction", "classic"}},
+		wantMongo: bson.M{
+			"title":  bson.M{"$regex": "dune", "$options": "i"},
+			"author": bson.M{"$regex": "herbert", "$options": "i"},
+			"genres": bson.M{"$all": []string{"science fiction", "classic"}},
+		},
+		wantIDs: []int64{1},
+	},
+	{
+		name:      "unknown genre",
+		filter:    BookFilter{Genres: []string{"horror"}},
+		wantSQL:   "genres @> $1",
+		wantArgs:  []any{[]string{"horror"}},
+		wantMongo: bson.M{"genres": bson.M{"$all": []string{"horror"}}},
+		wantIDs:   []int64{},
+	},
+	{
+		name:      "genres match case",
+		filter:    BookFilter{Genres: []string{"Classic"}},
+		wantSQL:   "genres @> $1",
+		wantArgs:  []any{[]string{"Classic"}},
+		wantMongo: bson.M{"genres": bson.M{"$all": []string{"Classic"}}},
+		wantIDs:   []int64{},
+	},
+	{
+		name:      "metacharacters are literal",
+		filter:    BookFilter{Title: "(illustrated)", Author: "j. r."},
+		wantSQL:   "title ~* $1 AND author ~* $2",
+		wantArgs:  []any{`\(illustrated\)`, `j\. r\.`},
+		wantMongo: bson.M{"title": bson.M{"$regex": `\(illustrated\)`, "$options": "i"}, "author": bson.M{"$regex": `j\. r\.`, "$options": "i"}},
+		wantIDs:   []int64{4},
+	},
+	{
+		name:      "prefix match",
+		filter:    BookFilter{Title: "dune m", Match: MatchPrefix},
+		wantSQL:   "title ~* $1",
+		wantArgs:  []any{"^dune m"},
+		wantMongo: bson.M{"title": bson.M{"$regex": "^dune m", "$options": "i"}},
+		wantIDs:   []int64{2},
+	},
+	{
+		name:      "exact match",
+		filter:    BookFilter{Title: "dune", Match: MatchExact},
+		wantSQL:   "title ~* $1",
+		wantArgs:  []any{"^dune$"},
+		wantMongo: bson.M{"title": bson.M{"$regex": "^dune$", "$options": "i"}},
+		wantIDs:   []int64{1, 5},
+	},
+	{
+		name:      "regex match",
+		filter:    BookFilter{Author: "^(jane|frank) ", Match: MatchRegex},
+		wantSQL:   "author ~* $1",
+		wantArgs:  []any{"^(jane|frank) "},
+		wantMongo: bson.M{"author": bson.M{"$regex": "^(jane|frank) ", "$options": "i"}},
+		wantIDs:   []int64{1, 2, 3},
+	},
+	{
+		name: "expression",
+		filter: BookFilter{Expr: FilterOr{Exprs: []FilterExpr{
+			FilterAnd{Exprs: []FilterExpr{
+				FilterCondition{Field: "year", Op: FilterGte, Value: int64(1960)},
+				FilterCondition{Field: "genres", Op: FilterAny, Value: []string{"fantasy", "horror"}},
+			}},
+			FilterCondition{Field: "author", Op: FilterEq, Value: "Jane Austen"},
+		}}},
+		wantSQL:  "(((year >= $1) AND (genres && $2)) OR (author = $3))",
+		wantArgs: []any{int64(1960), []string{"fantasy", "horror"}, "Jane Austen"},
+		wantMongo: bson.M{"$and": bson.A{bson.M{"$or": bson.A{
+			bson.M{"$and": bson.A{
+				bson.M{"year": bson.M{"$gte": int64(1960)}},
+				bson.M{"genres": bson.M{"$in": []string{"fantasy", "horror"}}},
+			}},
+			bson.M{"author": bson.M{"$eq": "Jane Austen"}},
+		}}}},
+		wantIDs: []int64{3, 5},
+	},
+	{
+		name: "negated expression",
+		filter: BookFilter{Genres: []string{"science fiction"}, Expr: FilterAnd{Exprs: []FilterExpr{
+			FilterNot{Expr: FilterCondition{Field: "genres", Op: FilterAll, Value: []string{"classic"}}},
+			FilterCondition{Field: "created_at", Op: FilterLt, Value: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}}},
+		wantSQL:  "genres @> $1 AND (NOT (genres @> $2) AND (created_at < $3))",
+		wantArgs: []any{[]string{"science fiction"}, []string{"classic"}, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)},
+		wantMongo: bson.M{
+			"genres": bson.M{"$all": []string{"science fiction"}},
+			"$and": bson.A{bson.M{"$and": bson.A{
+				bson.M{"$nor": bson.A{bson.M{"genres": bson.M{"$all": []string{"classic"}}}}},
+				bson.M{"createdat": bson.M{"$lt": time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			}}},
+		},
+		wantIDs: []int64{2},
+	},
+}
+
+func TestPostgresBookFilter(t *testing.T) {
+	for _, tt := range bookFilterTests {
+		t.Run(tt.name, func(t *testing.T) {
+			where := postgresBookFilter(tt.filter)
+
+			if got := where.String(); got != tt.wantSQL {
+				t.Errorf("got condition %q; want %q", got, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(where.args, tt.wantArgs) {
+				t.Errorf("got args %#v; want %#v", where.args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestMongoBookFilter(t *testing.T) {
+	for _, tt := range bookFilterTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mongoBookFilter(tt.filter); !reflect.DeepEqual(got, tt.wantMongo) {
+				t.Errorf("got filter %#v; want %#v", got, tt.wantMongo)
+			}
+		})
+	}
+}
+
+func TestMemoryBookFilter(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	for _, book := range filterTestBooks {
+		book := *book
+		if err := models.Books.Insert(ctx, &book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filters := Filters{Page: 1, PageSize: 2, Sort: "id", SortSafelist: []string{"id"}}
+
+	for _, tt := range bookFilterTests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The page is smaller than most results, so the totals have
+			// to come from the filter rather than the page.
+			books, metadata, err := models.Books.GetAll(ctx, tt.filter, filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if metadata.TotalRecords != len(tt.wantIDs) {
+				t.Errorf("got total_records %d; want %d", metadata.TotalRecords, len(tt.wantIDs))
+			}
+
+			want := tt.wantIDs
+			if len(want) > filters.PageSize {
+				want = want[:filters.PageSize]
+			}
+			got := make([]int64, len(books))
+			for i, book := range books {
+				got[i] = book.ID
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got books %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateBookFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter BookFilter
+		want   map[string]string
+	}{
+		{"empty", BookFilter{}, nil},
+		{"contains", BookFilter{Title: "dune", Author: "herbert", Match: MatchContains}, nil},
+		{"unknown match", BookFilter{Title: "dune", Match: "fuzzy"}, map[string]string{"match": "invalid match value"}},
+		{"long title", BookFilter{Title: strings.Repeat("a", 501)}, map[string]string{"title": "must not be more than 500 bytes long"}},
+		{"long author", BookFilter{Author: strings.Repeat("a", 501), Match: MatchPrefix}, map[string]string{"author": "must not be more than 500 bytes long"}},
+		{"regex", BookFilter{Title: "^dune( messiah)?$", Match: MatchRegex}, nil},
+		{"invalid regex", BookFilter{Title: "(dune", Match: MatchRegex}, map[string]string{"title": "must be a valid regular expression"}},
+		{"long regex", BookFilter{Author: strings.Repeat("a", 101), Match: MatchRegex}, map[string]string{"author": "must not be more than 100 bytes long"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateBookFilter(v, tt.filter)
+
+			if len(tt.want) == 0 {
+				if !v.Valid() {
+					t.Errorf("got errors %v; want none", v.Errors)
+				}
+				return
+			}
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("got errors %v; want %v", v.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoKeysetSortsOnLowerCase(t *testing.T) {
+	keys := []sortKey{{column: "title"}, {column: "id"}}
+
+	got := mongoKeyset(keys, []any{"Dune", int64(7)})
+	want := bson.M{"$or": bson.A{
+		bson.M{"title_lower": bson.M{"$gt": "dune"}},
+		bson.M{"title_lower": "dune", "id": bson.M{"$gt": int64(7)}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got keyset %#v; want %#v", got, want)
+	}
+}
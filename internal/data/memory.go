@@ -0,0 +1,167 @@
+package data
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a DB implementation that keeps every collection in process
+// memory. It is intended for local development and tests, and loses all
+// data when the process exits.
+type Memory struct {
+	mu              sync.RWMutex
+	lastBookID      int64
+	lastUserID      int64
+	lastAuthorID    int64
+	lastCopyID      int64
+	books           map[int64]Book
+	authors         map[int64]Author
+	bookAuthors     map[int64][]BookAuthor
+	copies          map[int64]Copy
+	users           map[int64]User
+	tokens          []Token
+	permissions     Permissions
+	userPermissions map[int64]Permissions
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		books:           make(map[int64]Book),
+		authors:         make(map[int64]Author),
+		bookAuthors:     make(map[int64][]BookAuthor),
+		copies:          make(map[int64]Copy),
+		users:           make(map[int64]User),
+		permissions:     Permissions{"books:read", "books:write", "copies:write"},
+		userPermissions: make(map[int64]Permissions),
+	}
+}
+
+func (m *Memory) Books() BookStore {
+	return memoryBookStore{m}
+}
+
+func (m *Memory) Authors() AuthorStore {
+	return memoryAuthorStore{m}
+}
+
+func (m *Memory) Copies() CopyStore {
+	return memoryCopyStore{m}
+}
+
+func (m *Memory) Users() UserStore {
+	return memoryUserStore{m}
+}
+
+func (m *Memory) Tokens() TokenStore {
+	return memoryTokenStore{m}
+}
+
+func (m *Memory) Permissions() PermissionStore {
+	return memoryPermissionStore{m}
+}
+
+func copyBook(book Book) Book {
+	if book.Genres != nil {
+		genres := make([]string, len(book.Genres))
+		copy(genres, book.Genres)
+		book.Genres = genres
+	}
+	return book
+}
+
+func containsAll(values []string, required []string) bool {
+	for _, r := range required {
+		found := false
+		for _, v := range values {
+			if v == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// WithTx runs fn against a private copy of the data while holding the write
+// lock, and only publishes the copy if fn succeeds. Other callers block until
+// the transaction finishes.
+func (m *Memory) WithTx(ctx context.Context, fn func(ctx context.Context, tx DB) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := m.clone()
+
+	err := fn(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	m.lastBookID = tx.lastBookID
+	m.lastUserID = tx.lastUserID
+	m.lastAuthorID = tx.lastAuthorID
+	m.lastCopyID = tx.lastCopyID
+	m.books = tx.books
+	m.authors = tx.authors
+	m.bookAuthors = tx.bookAuthors
+	m.copies = tx.copies
+	m.users = tx.users
+	m.tokens = tx.tokens
+	m.permissions = tx.permissions
+	m.userPermissions = tx.userPermissions
+	return nil
+}
+
+// clone copies the data of m. The caller must hold m.mu.
+func (m *Memory) clone() *Memory {
+	c := &Memory{
+		lastBookID:      m.lastBookID,
+		lastUserID:      m.lastUserID,
+		lastAuthorID:    m.lastAuthorID,
+		lastCopyID:      m.lastCopyID,
+		books:           make(map[int64]Book, len(m.books)),
+		authors:         make(map[int64]Author, len(m.authors)),
+		bookAuthors:     make(map[int64][]BookAuthor, len(m.bookAuthors)),
+		copies:          make(map[int64]Copy, len(m.copies)),
+		users:           make(map[int64]User, len(m.users)),
+		tokens:          make([]Token, len(m.tokens)),
+		permissions:     make(Permissions, len(m.permissions)),
+		userPermissions: make(map[int64]Permissions, len(m.userPermissions)),
+	}
+
+	for id, book := range m.books {
+		c.books[id] = copyBook(book)
+	}
+	for id, author := range m.authors {
+		c.authors[id] = author
+	}
+	for id, authors := range m.bookAuthors {
+		c.bookAuthors[id] = append([]BookAuthor(nil), authors...)
+	}
+	for id, item := range m.copies {
+		c.copies[id] = item
+	}
+	for id, user := range m.users {
+		c.users[id] = user
+	}
+	copy(c.tokens, m.tokens)
+	copy(c.permissions, m.permissions)
+	for id, permissions := range m.userPermissions {
+		c.userPermissions[id] = append(Permissions(nil), permissions...)
+	}
+
+	return c
+}
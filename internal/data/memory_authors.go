@@ -0,0 +1,198 @@
+package data
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"regexp"
+	"sort"
+)
+
+type memoryAuthorStore struct {
+	m *Memory
+}
+
+func (s memoryAuthorStore) Insert(_ context.Context, author *Author) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.lastAuthorID++
+	author.ID = s.m.lastAuthorID
+	s.m.authors[author.ID] = *author
+	return nil
+}
+
+func (s memoryAuthorStore) Get(_ context.Context, id int64) (*Author, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	author, ok := s.m.authors[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &author, nil
+}
+
+func (s memoryAuthorStore) GetAll(_ context.Context, name string, filters Filters) ([]*Author, Metadata, error) {
+	rx, err := regexp.Compile("(?i)" + authorNamePattern(name))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	s.m.mu.RLock()
+	matched := make([]*Author, 0, len(s.m.authors))
+	for _, author := range s.m.authors {
+		if rx.MatchString(author.Name) {
+			author := author
+			matched = append(matched, &author)
+		}
+	}
+	s.m.mu.RUnlock()
+
+	keys := filters.sortKeys()
+	collator := collate.New(language.Und, collate.IgnoreCase)
+
+	sort.Slice(matched, func(i, j int) bool {
+		for _, key := range keys {
+			var cmp int
+			switch key.column {
+			case "name":
+				cmp = collator.CompareString(matched[i].Name, matched[j].Name)
+			default:
+				cmp = compareInts(matched[i].ID, matched[j].ID)
+			}
+			if key.descending {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	totalRecords := len(matched)
+
+	start, end := filters.offset(), filters.offset()+filters.limit()
+	if start > len(matched) {
+		start = len(matched)
+	}
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	return matched[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (s memoryAuthorStore) Update(_ context.Context, author *Author) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	current, ok := s.m.authors[author.ID]
+	if !ok || current.Version != author.Version {
+		return ErrEditConflict
+	}
+
+	author.Version = uuid.New()
+	s.m.authors[author.ID] = *author
+	return nil
+}
+
+func (s memoryAuthorStore) Delete(_ context.Context, id int64) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.authors[id]; !ok {
+		return ErrRecordNotFound
+	}
+	delete(s.m.authors, id)
+
+	for bookID, authors := range s.m.bookAuthors {
+		kept := authors[:0:0]
+		for _, author := range authors {
+			if author.AuthorID != id {
+				kept = append(kept, author)
+			}
+		}
+		if len(kept) == len(authors) {
+			continue
+		}
+		s.m.bookAuthors[bookID] = kept
+
+		if book, ok := s.m.books[bookID]; ok {
+			book.Version = uuid.New()
+			s.m.books[bookID] = book
+		}
+	}
+	return nil
+}
+
+func (s memoryAuthorStore) GetForBooks(_ context.Context, bookIDs []int64) (map[int64][]BookAuthor, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	links := make(map[int64][]BookAuthor, len(bookIDs))
+	for _, bookID := range bookIDs {
+		for _, link := range s.m.bookAuthors[bookID] {
+			link.Name = s.m.authors[link.AuthorID].Name
+			links[bookID] = append(links[bookID], link)
+		}
+	}
+	return links, nil
+}
+
+func (s memoryAuthorStore) SetForBook(_ context.Context, bookID int64, authors []BookAuthor) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.books[bookID]; !ok {
+		return ErrRecordNotFound
+	}
+	for _, author := range authors {
+		if _, ok := s.m.authors[author.AuthorID]; !ok {
+			return ErrUnknownAuthor
+		}
+	}
+
+	links := make([]BookAuthor, len(authors))
+	for i, author := range authors {
+		links[i] = BookAuthor{AuthorID: author.AuthorID, Role: author.Role}
+	}
+	s.m.bookAuthors[bookID] = links
+	return nil
+}
+
+func (s memoryAuthorStore) LinkByName(_ context.Context, bookID int64, name string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if len(s.m.bookAuthors[bookID]) != 0 {
+		return nil
+	}
+
+	var authorID int64
+	for id, author := range s.m.authors {
+		if author.Name == name && (authorID == 0 || id < authorID) {
+			authorID = id
+		}
+	}
+	if authorID == 0 {
+		return nil
+	}
+
+	s.m.bookAuthors[bookID] = []BookAuthor{{AuthorID: authorID, Role: "author"}}
+	return nil
+}
+
+func (s memoryAuthorStore) ExistsByName(_ context.Context, name string) (bool, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	for _, author := range s.m.authors {
+		if author.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
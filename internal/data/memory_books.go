@@ -0,0 +1,509 @@
+package data
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type memoryBookStore struct {
+	m *Memory
+}
+
+// checkISBN enforces the uniqueness of ISBN-13s the other stores get from an
+// index. The caller must hold the write lock.
+func (s memoryBookStore) checkISBN(book *Book) error {
+	if book.ISBN13 == "" {
+		return nil
+	}
+	for _, existing := range s.m.books {
+		if existing.ID != book.ID && existing.ISBN13 == book.ISBN13 {
+			return &DuplicateKeyError{Field: "isbn_13"}
+		}
+	}
+	return nil
+}
+
+func (s memoryBookStore) Insert(_ context.Context, book *Book) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if err := s.checkISBN(book); err != nil {
+		return err
+	}
+
+	s.m.lastBookID++
+	book.ID = s.m.lastBookID
+	if book.WorkID == 0 {
+		book.WorkID = book.ID
+	}
+	s.m.books[book.ID] = copyBook(*book)
+	return nil
+}
+
+// projectBook clears the fields of book outside projection, mirroring what
+// the other stores load.
+func projectBook(book *Book, projection []string) {
+	if projection == nil {
+		return
+	}
+	if !selectsField(projection, "created_at") {
+		book.CreatedAt = time.Time{}
+	}
+	if !selectsField(projection, "title") {
+		book.Title = ""
+	}
+	if !selectsField(projection, "author") {
+		book.Author = ""
+	}
+	if !selectsField(projection, "year") {
+		book.Year = 0
+	}
+	if !selectsField(projection, "size") {
+		book.Size = 0
+	}
+	if !selectsField(projection, "genres") {
+		book.Genres = nil
+	}
+	if !selectsField(projection, "isbn_10") {
+		book.ISBN10 = ""
+	}
+	if !selectsField(projection, "isbn_13") {
+		book.ISBN13 = ""
+	}
+	if !selectsField(projection, "work_id") {
+		book.WorkID = 0
+	}
+	if !selectsField(projection, "format") {
+		book.Format = ""
+	}
+	if !selectsField(projection, "duration") {
+		book.Duration = 0
+	}
+	if !selectsField(projection, "publisher") {
+		book.Publisher = ""
+	}
+}
+
+func (s memoryBookStore) Get(_ context.Context, id int64, fields ...string) (*Book, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	book, ok := s.m.books[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	book = copyBook(book)
+	projectBook(&book, fields)
+	return &book, nil
+}
+
+func (s memoryBookStore) GetByISBN(_ context.Context, isbn13 string) (*Book, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	for _, book := range s.m.books {
+		if book.ISBN13 == isbn13 {
+			book = copyBook(book)
+			return &book, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+// memoryBookFilter is a BookFilter compiled for matching books in memory.
+type memoryBookFilter struct {
+	title  *regexp.Regexp
+	author *regexp.Regexp
+	genres []string
+	expr   FilterExpr
+	books  map[int64]bool
+	works  map[int64]bool
+	first  bool
+}
+
+// newMemoryBookFilter compiles f. The caller must hold m.mu, which guards the
+// author links the filter is built from.
+func newMemoryBookFilter(m *Memory, f BookFilter) (*memoryBookFilter, error) {
+	filter := &memoryBookFilter{genres: f.Genres, expr: f.Expr, first: f.FirstEditions}
+
+	if f.WorkIDs != nil {
+		filter.works = make(map[int64]bool, len(f.WorkIDs))
+		for _, id := range f.WorkIDs {
+			filter.works[id] = true
+		}
+	}
+
+	if f.AuthorID != 0 {
+		filter.books = make(map[int64]bool)
+		for bookID, authors := range m.bookAuthors {
+			for _, author := range authors {
+				if author.AuthorID == f.AuthorID {
+					filter.books[bookID] = true
+				}
+			}
+		}
+	}
+
+	var err error
+	if f.Title != "" {
+		filter.title, err = regexp.Compile("(?i)" + f.pattern(f.Title))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.Author != "" {
+		filter.author, err = regexp.Compile("(?i)" + f.pattern(f.Author))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
+
+func (f *memoryBookFilter) match(book *Book) bool {
+	if f.title != nil && !f.title.MatchString(book.Title) {
+		return false
+	}
+	if f.author != nil && !f.author.MatchString(book.Author) {
+		return false
+	}
+	if f.expr != nil && !matchFilterExpr(f.expr, book) {
+		return false
+	}
+	if f.books != nil && !f.books[book.ID] {
+		return false
+	}
+	if f.works != nil && !f.works[book.WorkID] {
+		return false
+	}
+	if f.first && book.ID != book.WorkID {
+		return false
+	}
+	return containsAll(book.Genres, f.genres)
+}
+
+// memoryTextScore scores book against s, weighting matches in the title
+// above the author and the author above the genres. It returns zero when
+// the book does not match.
+func memoryTextScore(s *TextSearch, book *Book) float64 {
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{searchTokens(book.Title), 1.0},
+		{searchTokens(book.Author), 0.4},
+		{searchTokens(strings.Join(book.Genres, " ")), 0.2},
+	}
+
+	var score float64
+
+	match := func(matches func(words []string) bool) bool {
+		found := false
+		for _, field := range fields {
+			if matches(field.words) {
+				score += field.weight
+				found = true
+			}
+		}
+		return found
+	}
+
+	for _, term := range s.Terms {
+		if !match(func(words []string) bool { return containsAll(words, []string{term}) }) {
+			return 0
+		}
+	}
+
+	for _, prefix := range s.Prefixes {
+		if !match(func(words []string) bool {
+			for _, word := range words {
+				if strings.HasPrefix(word, prefix) {
+					return true
+				}
+			}
+			return false
+		}) {
+			return 0
+		}
+	}
+
+	for _, phrase := range s.Phrases {
+		if !match(func(words []string) bool {
+			for i := 0; i+len(phrase) <= len(words); i++ {
+				if strings.Join(words[i:i+len(phrase)], " ") == strings.Join(phrase, " ") {
+					return true
+				}
+			}
+			return false
+		}) {
+			return 0
+		}
+	}
+
+	return score
+}
+
+// find returns copies of the books matching filter, in no particular order.
+func (s memoryBookStore) find(filter BookFilter) ([]*Book, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	matcher, err := newMemoryBookFilter(s.m, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]*Book, 0, len(s.m.books))
+	for _, book := range s.m.books {
+		if !matcher.match(&book) {
+			continue
+		}
+		book := copyBook(book)
+		if filter.Search != nil {
+			book.Score = memoryTextScore(filter.Search, &book)
+			if book.Score == 0 {
+				continue
+			}
+		}
+		matched = append(matched, &book)
+	}
+
+	return matched, nil
+}
+
+func (s memoryBookStore) GetAll(_ context.Context, filter BookFilter, filters Filters) ([]*Book, Metadata, error) {
+	matched, err := s.find(filter)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	keys := filters.walkKeys(c)
+	collator := collate.New(language.Und, collate.IgnoreCase)
+
+	// compare orders sort values the way the page is walked, comparing
+	// strings with a case-insensitive, locale-aware collation.
+	compare := func(a, b []any) int {
+		for i, key := range keys {
+			var cmp int
+			if s, ok := a[i].(string); ok {
+				cmp = collator.CompareString(s, b[i].(string))
+			} else {
+				cmp = compareSortValues(a[i], b[i])
+			}
+			if key.descending {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp
+			}
+		}
+		return 0
+	}
+
+	values := make(map[*Book][]any, len(matched))
+	for _, book := range matched {
+		for _, key := range keys {
+			values[book] = append(values[book], bookSortValue(book, key.column))
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return compare(values[matched[i]], values[matched[j]]) < 0
+	})
+
+	totalRecords := len(matched)
+
+	start := filters.offset()
+	if c != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return compare(values[matched[i]], c.Values) > 0
+		})
+	}
+	end := start + filters.fetchLimit(c)
+	if start > len(matched) {
+		start = len(matched)
+	}
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	projection := bookProjection(filters.Fields, filters.sortColumns()...)
+	for _, book := range matched[start:end] {
+		projectBook(book, projection)
+	}
+
+	return paginateBooks(matched[start:end], totalRecords, filters)
+}
+
+func (s memoryBookStore) Facets(_ context.Context, filter BookFilter, fields []string) (Facets, error) {
+	matched, err := s.find(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := make(Facets, len(fields))
+	for _, field := range fields {
+		var values []any
+		for _, book := range matched {
+			switch field {
+			case "genres":
+				for _, genre := range book.Genres {
+					values = append(values, genre)
+				}
+			case "year":
+				values = append(values, int64(book.Year))
+			case "author":
+				values = append(values, book.Author)
+			}
+		}
+		facets[field] = memoryFacet(field, values)
+	}
+
+	return facets, nil
+}
+
+// memoryFacet tallies values the way every store orders facets: years in
+// ascending order, other fields by descending count and then by value,
+// truncated to facetLimit.
+func memoryFacet(field string, values []any) []FacetCount {
+	counts := make(map[any]int)
+	for _, value := range values {
+		counts[value]++
+	}
+
+	facet := make([]FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facet = append(facet, FacetCount{Value: value, Count: count})
+	}
+
+	sort.Slice(facet, func(i, j int) bool {
+		if field != "year" && facet[i].Count != facet[j].Count {
+			return facet[i].Count > facet[j].Count
+		}
+		return compareSortValues(facet[i].Value, facet[j].Value) < 0
+	})
+
+	if field != "year" && len(facet) > facetLimit {
+		facet = facet[:facetLimit]
+	}
+
+	return facet
+}
+
+func (s memoryBookStore) Suggest(_ context.Context, field string, prefix string, limit int) ([]Suggestion, error) {
+	prefix = strings.ToLower(prefix)
+
+	s.m.mu.RLock()
+	var values []any
+	for _, book := range s.m.books {
+		candidates := book.Genres
+		switch field {
+		case "title":
+			candidates = []string{book.Title}
+		case "author":
+			candidates = []string{book.Author}
+		}
+		for _, value := range candidates {
+			if strings.HasPrefix(strings.ToLower(value), prefix) {
+				values = append(values, value)
+			}
+		}
+	}
+	s.m.mu.RUnlock()
+
+	facet := memoryFacet(field, values)
+
+	suggestions := make([]Suggestion, 0, limit)
+	for _, count := range facet {
+		if len(suggestions) == limit {
+			break
+		}
+		suggestions = append(suggestions, Suggestion{Value: count.Value.(string), Count: count.Count})
+	}
+
+	return suggestions, nil
+}
+
+func (s memoryBookStore) Update(_ context.Context, book *Book) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	current, ok := s.m.books[book.ID]
+	if !ok || current.Version != book.Version {
+		return ErrEditConflict
+	}
+	if err := s.checkISBN(book); err != nil {
+		return err
+	}
+
+	book.Version = uuid.New()
+	s.m.books[book.ID] = copyBook(*book)
+	return nil
+}
+
+// moveWork moves the work whose first edition, id, was deleted to the next
+// edition. The caller must hold the write lock.
+func (s memoryBookStore) moveWork(id int64) {
+	var next int64
+	for _, book := range s.m.books {
+		if book.WorkID == id && (next == 0 || book.ID < next) {
+			next = book.ID
+		}
+	}
+	if next == 0 {
+		return
+	}
+
+	for bookID, book := range s.m.books {
+		if book.WorkID == id {
+			book.WorkID = next
+			book.Version = uuid.New()
+			s.m.books[bookID] = book
+		}
+	}
+}
+
+func (s memoryBookStore) Delete(_ context.Context, id int64) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.books[id]; !ok {
+		return ErrRecordNotFound
+	}
+	delete(s.m.books, id)
+	delete(s.m.bookAuthors, id)
+	s.m.deleteCopiesOf(id)
+	s.moveWork(id)
+	return nil
+}
+
+func (s memoryBookStore) DeleteVersion(_ context.Context, id int64, version uuid.UUID) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	book, ok := s.m.books[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	if book.Version != version {
+		return ErrEditConflict
+	}
+	delete(s.m.books, id)
+	delete(s.m.bookAuthors, id)
+	s.m.deleteCopiesOf(id)
+	s.moveWork(id)
+	return nil
+}
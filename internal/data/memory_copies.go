@@ -0,0 +1,183 @@
+package data
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"sort"
+	"strings"
+)
+
+type memoryCopyStore struct {
+	m *Memory
+}
+
+// checkBarcode reports a copy other than c holding its barcode. The caller
+// must hold the lock.
+func (s memoryCopyStore) checkBarcode(c *Copy) error {
+	for _, other := range s.m.copies {
+		if other.ID != c.ID && other.Barcode == c.Barcode {
+			return &DuplicateKeyError{Field: "barcode"}
+		}
+	}
+	return nil
+}
+
+func (s memoryCopyStore) Insert(_ context.Context, c *Copy) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.books[c.BookID]; !ok {
+		return ErrRecordNotFound
+	}
+	if err := s.checkBarcode(c); err != nil {
+		return err
+	}
+
+	s.m.lastCopyID++
+	c.ID = s.m.lastCopyID
+	s.m.copies[c.ID] = *c
+	return nil
+}
+
+func (s memoryCopyStore) Get(_ context.Context, id int64) (*Copy, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	c, ok := s.m.copies[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &c, nil
+}
+
+func (s memoryCopyStore) GetAll(_ context.Context, filter CopyFilter, filters Filters) ([]*Copy, Metadata, error) {
+	s.m.mu.RLock()
+	matched := make([]*Copy, 0)
+	for _, c := range s.m.copies {
+		if c.BookID != filter.BookID {
+			continue
+		}
+		if filter.Status != "" && c.Status != filter.Status {
+			continue
+		}
+		if filter.Branch != "" && !strings.EqualFold(c.Branch, filter.Branch) {
+			continue
+		}
+		c := c
+		matched = append(matched, &c)
+	}
+	s.m.mu.RUnlock()
+
+	keys := filters.sortKeys()
+	collator := collate.New(language.Und, collate.IgnoreCase)
+
+	sort.Slice(matched, func(i, j int) bool {
+		for _, key := range keys {
+			var cmp int
+			switch key.column {
+			case "barcode":
+				cmp = collator.CompareString(matched[i].Barcode, matched[j].Barcode)
+			case "branch":
+				cmp = collator.CompareString(matched[i].Branch, matched[j].Branch)
+			case "status":
+				cmp = strings.Compare(matched[i].Status, matched[j].Status)
+			default:
+				cmp = compareInts(matched[i].ID, matched[j].ID)
+			}
+			if key.descending {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	totalRecords := len(matched)
+
+	start, end := filters.offset(), filters.offset()+filters.limit()
+	if start > len(matched) {
+		start = len(matched)
+	}
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	return matched[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (s memoryCopyStore) Update(_ context.Context, c *Copy) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	current, ok := s.m.copies[c.ID]
+	if !ok || current.Version != c.Version {
+		return ErrEditConflict
+	}
+	if err := s.checkBarcode(c); err != nil {
+		return err
+	}
+
+	c.Version = uuid.New()
+	s.m.copies[c.ID] = *c
+	return nil
+}
+
+func (s memoryCopyStore) Delete(_ context.Context, id int64) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.copies[id]; !ok {
+		return ErrRecordNotFound
+	}
+	delete(s.m.copies, id)
+	return nil
+}
+
+func (s memoryCopyStore) DeleteVersion(_ context.Context, id int64, version uuid.UUID) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	c, ok := s.m.copies[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	if c.Version != version {
+		return ErrEditConflict
+	}
+	delete(s.m.copies, id)
+	return nil
+}
+
+func (s memoryCopyStore) CountForBooks(_ context.Context, bookIDs []int64) (map[int64]Availability, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	wanted := make(map[int64]bool, len(bookIDs))
+	for _, id := range bookIDs {
+		wanted[id] = true
+	}
+
+	counts := make(map[int64]Availability, len(bookIDs))
+	for _, c := range s.m.copies {
+		if wanted[c.BookID] {
+			availability := counts[c.BookID]
+			availability.add(c.Status, 1)
+			counts[c.BookID] = availability
+		}
+	}
+	return counts, nil
+}
+
+// deleteCopiesOf deletes the copies of a book, as deleting the book does in
+// the other stores. The caller must hold the write lock.
+func (m *Memory) deleteCopiesOf(bookID int64) {
+	for id, c := range m.copies {
+		if c.BookID == bookID {
+			delete(m.copies, id)
+		}
+	}
+}
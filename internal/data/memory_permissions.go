@@ -0,0 +1,30 @@
+package data
+
+import (
+	"context"
+)
+
+type memoryPermissionStore struct {
+	m *Memory
+}
+
+func (s memoryPermissionStore) GetAllForUser(_ context.Context, userID int64) (Permissions, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	permissions := make(Permissions, len(s.m.userPermissions[userID]))
+	copy(permissions, s.m.userPermissions[userID])
+	return permissions, nil
+}
+
+func (s memoryPermissionStore) AddForUser(_ context.Context, userID int64, codes ...string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, code := range codes {
+		if s.m.permissions.Include(code) && !s.m.userPermissions[userID].Include(code) {
+			s.m.userPermissions[userID] = append(s.m.userPermissions[userID], code)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+type memoryTokenStore struct {
+	m *Memory
+}
+
+func (s memoryTokenStore) Insert(_ context.Context, token *Token) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.tokens = append(s.m.tokens, *token)
+	return nil
+}
+
+func (s memoryTokenStore) DeleteAllForUser(_ context.Context, scope string, userID int64) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	tokens := s.m.tokens[:0]
+	for _, token := range s.m.tokens {
+		if token.UserID != userID || token.Scope != scope {
+			tokens = append(tokens, token)
+		}
+	}
+	s.m.tokens = tokens
+	return nil
+}
+
+func (s memoryTokenStore) DeleteExpired(_ context.Context, now time.Time) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var deleted int64
+
+	tokens := s.m.tokens[:0]
+	for _, token := range s.m.tokens {
+		if token.Expiry.After(now) {
+			tokens = append(tokens, token)
+		} else {
+			deleted++
+		}
+	}
+	s.m.tokens = tokens
+	return deleted, nil
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+type memoryUserStore struct {
+	m *Memory
+}
+
+func (s memoryUserStore) Insert(_ context.Context, user *User) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, existing := range s.m.users {
+		if strings.EqualFold(existing.Email, user.Email) {
+			return &DuplicateKeyError{Field: "email"}
+		}
+	}
+
+	s.m.lastUserID++
+	user.ID = s.m.lastUserID
+	s.m.users[user.ID] = *user
+	return nil
+}
+
+func (s memoryUserStore) GetByEmail(_ context.Context, email string) (*User, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	for _, user := range s.m.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (s memoryUserStore) GetForToken(_ context.Context, scope string, hash []byte, now time.Time) (*User, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	for _, token := range s.m.tokens {
+		if token.Scope == scope && bytes.Equal(token.Hash, hash) && token.Expiry.After(now) {
+			if user, ok := s.m.users[token.UserID]; ok {
+				return &user, nil
+			}
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (s memoryUserStore) Update(_ context.Context, user *User) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	current, ok := s.m.users[user.ID]
+	if !ok || current.Version != user.Version {
+		return ErrEditConflict
+	}
+	for id, existing := range s.m.users {
+		if id != user.ID && strings.EqualFold(existing.Email, user.Email) {
+			return &DuplicateKeyError{Field: "email"}
+		}
+	}
+
+	user.Version = uuid.New()
+	s.m.users[user.ID] = *user
+	return nil
+}
@@ -1,27 +1,61 @@
 package data
 
 import (
-	"errors"
-)
-
-var (
-	ErrRecordNotFound = errors.New("record not found")
-	ErrEditConflict   = errors.New("edit conflict")
+	"context"
+	"time"
 )
 
 type Models struct {
 	Books       BookModel
+	Authors     AuthorModel
+	Copies      CopyModel
 	Users       UserModel
 	Tokens      TokenModel
 	Permissions PermissionModel
+
+	db      DB
+	timeout time.Duration
+	now     func() time.Time
 }
 
-func NewModels(db DB) Models {
+// NewModels wires the stores of db into models. Every model operation runs
+// under the caller's context, bounded by timeout.
+func NewModels(db DB, timeout time.Duration) Models {
+	return newModels(db, timeout, time.Now)
+}
+
+func newModels(db DB, timeout time.Duration, now func() time.Time) Models {
 	return Models{
-		Books:       BookModel{DB: db},
-		Users:       UserModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Permissions: PermissionModel{DB: db},
+		Books:       BookModel{Store: db.Books(), Timeout: timeout},
+		Authors:     AuthorModel{Store: db.Authors(), Timeout: timeout},
+		Copies:      CopyModel{Store: db.Copies(), Timeout: timeout},
+		Users:       UserModel{Store: db.Users(), Timeout: timeout, Now: now},
+		Tokens:      TokenModel{Store: db.Tokens(), Timeout: timeout, Now: now},
+		Permissions: PermissionModel{Store: db.Permissions(), Timeout: timeout},
+		db:          db,
+		timeout:     timeout,
+		now:         now,
 	}
 
 }
+
+// WithClock returns a copy of m that reads the current time from now, which
+// decides token expiry.
+func (m Models) WithClock(now func() time.Time) Models {
+	return newModels(m.db, m.timeout, now)
+}
+
+// WithTx runs fn with a set of models whose writes all belong to one
+// transaction, committed only if fn returns nil.
+func (m Models) WithTx(ctx context.Context, fn func(ctx context.Context, tx Models) error) error {
+	return m.db.WithTx(ctx, func(ctx context.Context, tx DB) error {
+		return fn(ctx, newModels(tx, m.timeout, m.now))
+	})
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,234 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoAuthorStore keeps authors in their own collection, and the links of a
+// book in an authors array on the book document, so books can be filtered by
+// author without a join.
+type mongoAuthorStore struct {
+	db       *mongo.Database
+	coll     *mongo.Collection
+	counters *mongoCounters
+}
+
+func (s mongoAuthorStore) Insert(ctx context.Context, author *Author) error {
+	id, err := s.counters.next(ctx, s.db, "authors")
+	if err != nil {
+		return mongoError(err)
+	}
+
+	author.ID = id
+
+	_, err = s.coll.InsertOne(ctx, author)
+	return mongoError(err)
+}
+
+func (s mongoAuthorStore) Get(ctx context.Context, id int64) (*Author, error) {
+	var author Author
+
+	err := s.coll.FindOne(ctx, bson.M{"id": id}).Decode(&author)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &author, nil
+}
+
+func (s mongoAuthorStore) GetAll(ctx context.Context, name string, filters Filters) ([]*Author, Metadata, error) {
+	filter := bson.M{"name": bson.M{"$regex": authorNamePattern(name), "$options": "i"}}
+
+	totalRecords, err := s.coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, Metadata{}, mongoError(err)
+	}
+
+	sort := bson.D{}
+	for _, key := range filters.sortKeys() {
+		sort = append(sort, bson.E{Key: key.column, Value: mongoDirection(!key.descending)})
+	}
+
+	opts := options.Find().
+		SetCollation(mongoCollation).
+		SetSort(sort).
+		SetSkip(int64(filters.offset())).
+		SetLimit(int64(filters.limit()))
+
+	cursor, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, Metadata{}, mongoError(err)
+	}
+
+	authors := []*Author{}
+	if err = cursor.All(ctx, &authors); err != nil {
+		return nil, Metadata{}, mongoError(err)
+	}
+
+	return authors, calculateMetadata(int(totalRecords), filters.Page, filters.PageSize), nil
+}
+
+func (s mongoAuthorStore) Update(ctx context.Context, author *Author) error {
+	filter := bson.M{"id": author.ID, "version": author.Version}
+	version := uuid.New()
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":    author.Name,
+			"version": version,
+		},
+	}
+
+	result, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return mongoError(err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrEditConflict
+	}
+
+	author.Version = version
+	return nil
+}
+
+// Delete removes the author and its links to books in one transaction. The
+// linked books get a new version, since their authors changed.
+func (s mongoAuthorStore) Delete(ctx context.Context, id int64) error {
+	return mongoTx(ctx, s.db, func(ctx context.Context) error {
+		result, err := s.coll.DeleteOne(ctx, bson.M{"id": id})
+		if err != nil {
+			return mongoError(err)
+		}
+
+		if result.DeletedCount == 0 {
+			return ErrRecordNotFound
+		}
+
+		update := bson.M{
+			"$pull": bson.M{"authors": bson.M{"author_id": id}},
+			"$set":  bson.M{"version": uuid.New()},
+		}
+
+		_, err = s.db.Collection("books").UpdateMany(ctx, bson.M{"authors.author_id": id}, update)
+		return mongoError(err)
+	})
+}
+
+func (s mongoAuthorStore) GetForBooks(ctx context.Context, bookIDs []int64) (map[int64][]BookAuthor, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 0, "id": 1, "authors": 1})
+
+	cursor, err := s.db.Collection("books").Find(ctx, bson.M{"id": bson.M{"$in": bookIDs}, "authors.0": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var books []struct {
+		ID      int64        `bson:"id"`
+		Authors []BookAuthor `bson:"authors"`
+	}
+	if err = cursor.All(ctx, &books); err != nil {
+		return nil, mongoError(err)
+	}
+
+	var authorIDs []int64
+	for _, book := range books {
+		for _, link := range book.Authors {
+			authorIDs = append(authorIDs, link.AuthorID)
+		}
+	}
+
+	cursor, err = s.coll.Find(ctx, bson.M{"id": bson.M{"$in": authorIDs}})
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var authors []Author
+	if err = cursor.All(ctx, &authors); err != nil {
+		return nil, mongoError(err)
+	}
+
+	names := make(map[int64]string, len(authors))
+	for _, author := range authors {
+		names[author.ID] = author.Name
+	}
+
+	links := make(map[int64][]BookAuthor, len(books))
+	for _, book := range books {
+		for _, link := range book.Authors {
+			link.Name = names[link.AuthorID]
+			links[book.ID] = append(links[book.ID], link)
+		}
+	}
+
+	return links, nil
+}
+
+func (s mongoAuthorStore) SetForBook(ctx context.Context, bookID int64, authors []BookAuthor) error {
+	ids := make(map[int64]bool, len(authors))
+	for _, author := range authors {
+		ids[author.AuthorID] = true
+	}
+
+	distinct := make([]int64, 0, len(ids))
+	for id := range ids {
+		distinct = append(distinct, id)
+	}
+
+	count, err := s.coll.CountDocuments(ctx, bson.M{"id": bson.M{"$in": distinct}})
+	if err != nil {
+		return mongoError(err)
+	}
+	if int(count) != len(distinct) {
+		return ErrUnknownAuthor
+	}
+
+	links := make([]BookAuthor, len(authors))
+	for i, author := range authors {
+		links[i] = BookAuthor{AuthorID: author.AuthorID, Role: author.Role}
+	}
+
+	result, err := s.db.Collection("books").UpdateOne(ctx, bson.M{"id": bookID}, bson.M{"$set": bson.M{"authors": links}})
+	if err != nil {
+		return mongoError(err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (s mongoAuthorStore) LinkByName(ctx context.Context, bookID int64, name string) error {
+	var author Author
+
+	opts := options.FindOne().SetSort(bson.M{"id": 1}).SetProjection(bson.M{"_id": 0, "id": 1})
+	err := s.coll.FindOne(ctx, bson.M{"name": name}, opts).Decode(&author)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return mongoError(err)
+	}
+
+	filter := bson.M{"id": bookID, "authors.0": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"authors": []BookAuthor{{AuthorID: author.ID, Role: "author"}}}}
+
+	_, err = s.db.Collection("books").UpdateOne(ctx, filter, update)
+	return mongoError(err)
+}
+
+func (s mongoAuthorStore) ExistsByName(ctx context.Context, name string) (bool, error) {
+	count, err := s.coll.CountDocuments(ctx, bson.M{"name": name}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, mongoError(err)
+	}
+
+	return count != 0, nil
+}
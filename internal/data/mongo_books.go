@@ -0,0 +1,558 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+	"strings"
+)
+
+type mongoBookStore struct {
+	db       *mongo.Database
+	coll     *mongo.Collection
+	counters *mongoCounters
+}
+
+// mongoBook is the document a book is stored as. Alongside the book it keeps
+// lower-cased copies of the suggested fields, so that Suggest can match a
+// prefix with an anchored, case-sensitive regex that uses their indexes.
+type mongoBook struct {
+	*Book       `bson:",inline"`
+	TitleLower  string   `bson:"title_lower"`
+	AuthorLower string   `bson:"author_lower"`
+	GenresLower []string `bson:"genres_lower"`
+}
+
+func newMongoBook(book *Book) mongoBook {
+	genres := make([]string, len(book.Genres))
+	for i, genre := range book.Genres {
+		genres[i] = strings.ToLower(genre)
+	}
+
+	return mongoBook{
+		Book:        book,
+		TitleLower:  strings.ToLower(book.Title),
+		AuthorLower: strings.ToLower(book.Author),
+		GenresLower: genres,
+	}
+}
+
+func (s mongoBookStore) Insert(ctx context.Context, book *Book) error {
+	id, err := s.counters.next(ctx, s.db, "books")
+	if err != nil {
+		return mongoError(err)
+	}
+
+	book.ID = id
+	if book.WorkID == 0 {
+		book.WorkID = id
+	}
+
+	_, err = s.coll.InsertOne(ctx, newMongoBook(book))
+	return mongoError(err)
+}
+
+// mongoProjection turns a projection into a projection document. A nil
+// projection loads whole documents.
+func mongoProjection(projection []string) bson.M {
+	if projection == nil {
+		return nil
+	}
+
+	doc := bson.M{"_id": 0}
+	for _, field := range projection {
+		doc[mongoField(field)] = 1
+	}
+	return doc
+}
+
+func (s mongoBookStore) Get(ctx context.Context, id int64, fields ...string) (*Book, error) {
+	var book Book
+
+	opts := options.FindOne()
+	if projection := mongoProjection(fields); projection != nil {
+		opts.SetProjection(projection)
+	}
+
+	err := s.coll.FindOne(ctx, bson.M{"id": id}, opts).Decode(&book)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &book, nil
+}
+
+func (s mongoBookStore) GetByISBN(ctx context.Context, isbn13 string) (*Book, error) {
+	var book Book
+
+	err := s.coll.FindOne(ctx, bson.M{"isbn_13": isbn13}).Decode(&book)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &book, nil
+}
+
+// mongoBookFilter translates f into a query document. The same document is
+// used to count and to find, so the totals always describe the listed books.
+func mongoBookFilter(f BookFilter) bson.M {
+	filter := bson.M{}
+	if f.Title != "" {
+		filter["title"] = bson.M{"$regex": f.pattern(f.Title), "$options": "i"}
+	}
+	if f.Author != "" {
+		filter["author"] = bson.M{"$regex": f.pattern(f.Author), "$options": "i"}
+	}
+	if len(f.Genres) != 0 {
+		filter["genres"] = bson.M{"$all": f.Genres}
+	}
+	if f.AuthorID != 0 {
+		filter["authors.author_id"] = f.AuthorID
+	}
+	if f.WorkIDs != nil {
+		filter["work_id"] = bson.M{"$in": f.WorkIDs}
+	}
+	if f.FirstEditions {
+		filter["$expr"] = bson.M{"$eq": bson.A{"$id", "$work_id"}}
+	}
+
+	var and bson.A
+	if f.Expr != nil {
+		and = append(and, mongoFilterExpr(f.Expr))
+	}
+	if f.Search != nil {
+		if text := mongoTextSearch(f.Search); text != "" {
+			filter["$text"] = bson.M{"$search": text}
+		}
+		for _, prefix := range f.Search.Prefixes {
+			rx := bson.M{"$regex": `(^|[^\p{L}\p{N}])` + regexp.QuoteMeta(prefix), "$options": "i"}
+			and = append(and, bson.M{"$or": bson.A{
+				bson.M{"title": rx},
+				bson.M{"author": rx},
+				bson.M{"genres": rx},
+			}})
+		}
+	}
+	if len(and) != 0 {
+		filter["$and"] = and
+	}
+
+	return filter
+}
+
+// mongoTextSearch renders the terms and phrases of s as a $text search
+// string. Each term is quoted so that MongoDB requires all of them rather
+// than any. Prefixes are not supported by text indexes and are matched
+// separately.
+func mongoTextSearch(s *TextSearch) string {
+	var parts []string
+
+	for _, term := range s.Terms {
+		parts = append(parts, `"`+term+`"`)
+	}
+	for _, phrase := range s.Phrases {
+		parts = append(parts, `"`+strings.Join(phrase, " ")+`"`)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// mongoFilterFields maps filter fields to the keys books are stored under.
+// Book has no bson tags, so the driver lowercases the Go field names.
+var mongoFilterFields = map[string]string{
+	"created_at": "createdat",
+}
+
+var mongoFilterOps = map[FilterOp]string{
+	FilterEq:  "$eq",
+	FilterNe:  "$ne",
+	FilterLt:  "$lt",
+	FilterLte: "$lte",
+	FilterGt:  "$gt",
+	FilterGte: "$gte",
+	FilterAny: "$in",
+	FilterAll: "$all",
+}
+
+// mongoField returns the document key a book field is stored under.
+func mongoField(field string) string {
+	if key, ok := mongoFilterFields[field]; ok {
+		return key
+	}
+	return field
+}
+
+func mongoFilterExpr(e FilterExpr) bson.M {
+	switch e := e.(type) {
+	case FilterAnd:
+		return bson.M{"$and": mongoFilterExprs(e.Exprs)}
+	case FilterOr:
+		return bson.M{"$or": mongoFilterExprs(e.Exprs)}
+	case FilterNot:
+		return bson.M{"$nor": bson.A{mongoFilterExpr(e.Expr)}}
+	case FilterCondition:
+		return bson.M{mongoField(e.Field): bson.M{mongoFilterOps[e.Op]: e.Value}}
+	default:
+		panic("unknown filter expression")
+	}
+}
+
+func mongoFilterExprs(exprs []FilterExpr) bson.A {
+	docs := make(bson.A, len(exprs))
+	for i, expr := range exprs {
+		docs[i] = mongoFilterExpr(expr)
+	}
+	return docs
+}
+
+func mongoDirection(ascending bool) int {
+	if ascending {
+		return 1
+	}
+	return -1
+}
+
+func mongoComparison(ascending bool) string {
+	if ascending {
+		return "$gt"
+	}
+	return "$lt"
+}
+
+// mongoCollation orders and compares strings case-insensitively. It changes
+// matching as well as sorting, so it is only set on queries whose string
+// predicates are regexes, which ignore it, or lower-case enum values.
+var mongoCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// mongoSortFields maps the columns that sort case-insensitively to the
+// lower-cased copies books are stored with. Sorting on those copies leaves
+// the query without a collation, so equality and $all match case-sensitively
+// as they do in the other stores.
+var mongoSortFields = map[string]string{
+	"title":  "title_lower",
+	"author": "author_lower",
+}
+
+// mongoSortField returns the document key books are sorted on by column.
+func mongoSortField(column string) string {
+	if key, ok := mongoSortFields[column]; ok {
+		return key
+	}
+	return mongoField(column)
+}
+
+// mongoSortValue returns value, a cursor value of column, as it compares
+// against mongoSortField(column).
+func mongoSortValue(column string, value any) any {
+	if _, ok := mongoSortFields[column]; ok {
+		if s, ok := value.(string); ok {
+			return strings.ToLower(s)
+		}
+	}
+	return value
+}
+
+// mongoKeyset builds the filter selecting the books after values in the
+// order of keys: the first key is past its value, or equal with the second
+// key past its value, and so on.
+func mongoKeyset(keys []sortKey, values []any) bson.M {
+	alternatives := make(bson.A, len(keys))
+	for i, key := range keys {
+		alternative := bson.M{}
+		for j := 0; j < i; j++ {
+			alternative[mongoSortField(keys[j].column)] = mongoSortValue(keys[j].column, values[j])
+		}
+		alternative[mongoSortField(key.column)] = bson.M{mongoComparison(!key.descending): mongoSortValue(key.column, values[i])}
+		alternatives[i] = alternative
+	}
+	return bson.M{"$or": alternatives}
+}
+
+func (s mongoBookStore) GetAll(ctx context.Context, filter BookFilter, filters Filters) ([]*Book, Metadata, error) {
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	query := mongoBookFilter(filter)
+
+	var totalRecords int64
+
+	if !filters.SkipCount {
+		totalRecords, err = s.coll.CountDocuments(ctx, query)
+		if err != nil {
+			return nil, Metadata{}, mongoError(err)
+		}
+	}
+
+	_, text := query["$text"]
+
+	keys := filters.walkKeys(c)
+
+	sort := bson.D{}
+	for _, key := range keys {
+		switch key.column {
+		case "relevance":
+			if text {
+				sort = append(sort, bson.E{Key: "score", Value: bson.M{"$meta": "textScore"}})
+			}
+		default:
+			sort = append(sort, bson.E{Key: mongoSortField(key.column), Value: mongoDirection(!key.descending)})
+		}
+	}
+
+	opts := options.Find().
+		SetSort(sort).
+		SetSkip(int64(filters.offset())).
+		SetLimit(int64(filters.fetchLimit(c)))
+
+	projection := mongoProjection(bookProjection(filters.Fields, filters.sortColumns()...))
+	if text {
+		if projection == nil {
+			projection = bson.M{}
+		}
+		projection["score"] = bson.M{"$meta": "textScore"}
+	}
+	if projection != nil {
+		opts.SetProjection(projection)
+	}
+
+	if c != nil {
+		query = bson.M{"$and": bson.A{query, mongoKeyset(keys, c.Values)}}
+
+		opts.SetSkip(0)
+	}
+
+	cursor, err := s.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, Metadata{}, mongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	result := make([]*Book, 0, filters.limit())
+
+	for cursor.Next(ctx) {
+		var book *Book
+		if err = cursor.Decode(&book); err != nil {
+			return nil, Metadata{}, mongoError(err)
+		}
+		result = append(result, book)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, Metadata{}, mongoError(err)
+	}
+
+	return paginateBooks(result, int(totalRecords), filters)
+}
+
+// mongoFacetStages holds the $facet pipeline of each facet, matching the
+// ordering and limits of the other stores.
+var mongoFacetStages = map[string]bson.A{
+	"genres": {
+		bson.M{"$unwind": "$genres"},
+		bson.M{"$group": bson.M{"_id": "$genres", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": 20},
+	},
+	"year": {
+		bson.M{"$group": bson.M{"_id": "$year", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	},
+	"author": {
+		bson.M{"$group": bson.M{"_id": "$author", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": 20},
+	},
+}
+
+func (s mongoBookStore) Facets(ctx context.Context, filter BookFilter, fields []string) (Facets, error) {
+	stages := bson.M{}
+	for _, field := range fields {
+		stages[field] = mongoFacetStages[field]
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": mongoBookFilter(filter)},
+		bson.M{"$facet": stages},
+	}
+
+	cursor, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	var result []map[string][]struct {
+		Value any `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return nil, mongoError(err)
+	}
+
+	facets := make(Facets, len(fields))
+	for _, field := range fields {
+		facet := []FacetCount{}
+		if len(result) != 0 {
+			for _, count := range result[0][field] {
+				facet = append(facet, FacetCount{Value: count.Value, Count: count.Count})
+			}
+		}
+		facets[field] = facet
+	}
+
+	return facets, nil
+}
+
+func (s mongoBookStore) Suggest(ctx context.Context, field string, prefix string, limit int) ([]Suggestion, error) {
+	pattern := "^" + regexp.QuoteMeta(strings.ToLower(prefix))
+
+	pipeline := bson.A{bson.M{"$match": bson.M{field + "_lower": bson.M{"$regex": pattern}}}}
+	if field == "genres" {
+		// Only some genres of a matched book need to share the prefix. The
+		// unwound genres are few, so they are matched without an index.
+		pipeline = append(pipeline,
+			bson.M{"$unwind": "$genres"},
+			bson.M{"$match": bson.M{"genres": bson.M{"$regex": pattern, "$options": "i"}}},
+		)
+	}
+	pipeline = append(pipeline,
+		bson.M{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": limit},
+	)
+
+	cursor, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	suggestions := []Suggestion{}
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			Value string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err = cursor.Decode(&doc); err != nil {
+			return nil, mongoError(err)
+		}
+		suggestions = append(suggestions, Suggestion{Value: doc.Value, Count: doc.Count})
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, mongoError(err)
+	}
+
+	return suggestions, nil
+}
+
+func (s mongoBookStore) Update(ctx context.Context, book *Book) error {
+	filter := bson.M{"id": book.ID, "version": book.Version}
+	version := uuid.New()
+	doc := newMongoBook(book)
+	update := bson.M{
+		"$set": bson.M{
+			"title":        book.Title,
+			"author":       book.Author,
+			"size":         book.Size,
+			"year":         book.Year,
+			"genres":       book.Genres,
+			"isbn_10":      book.ISBN10,
+			"isbn_13":      book.ISBN13,
+			"format":       book.Format,
+			"duration":     book.Duration,
+			"publisher":    book.Publisher,
+			"title_lower":  doc.TitleLower,
+			"author_lower": doc.AuthorLower,
+			"genres_lower": doc.GenresLower,
+			"version":      version,
+		},
+	}
+
+	result, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return mongoError(err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrEditConflict
+	}
+
+	book.Version = version
+	return nil
+}
+
+// Delete removes the book, its copies and its place as the first edition of
+// a work in one transaction.
+func (s mongoBookStore) Delete(ctx context.Context, id int64) error {
+	return mongoTx(ctx, s.db, func(ctx context.Context) error {
+		result, err := s.coll.DeleteOne(ctx, bson.M{"id": id})
+		if err != nil {
+			return mongoError(err)
+		}
+
+		if result.DeletedCount == 0 {
+			return ErrRecordNotFound
+		}
+		return s.deleted(ctx, id)
+	})
+}
+
+// deleted removes what depends on the deleted book id: its copies, and its
+// place as the first edition of a work.
+func (s mongoBookStore) deleted(ctx context.Context, id int64) error {
+	_, err := s.db.Collection("copies").DeleteMany(ctx, bson.M{"book_id": id})
+	if err != nil {
+		return mongoError(err)
+	}
+	return s.moveWork(ctx, id)
+}
+
+// moveWork moves the work whose first edition, id, was deleted to the next
+// edition, giving the moved editions a new version. It does nothing if id was
+// not the first edition of a work.
+func (s mongoBookStore) moveWork(ctx context.Context, id int64) error {
+	var next struct {
+		ID int64 `bson:"id"`
+	}
+
+	opts := options.FindOne().SetSort(bson.M{"id": 1}).SetProjection(bson.M{"_id": 0, "id": 1})
+	err := s.coll.FindOne(ctx, bson.M{"work_id": id}, opts).Decode(&next)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return mongoError(err)
+	}
+
+	update := bson.M{"$set": bson.M{"work_id": next.ID, "version": uuid.New()}}
+
+	_, err = s.coll.UpdateMany(ctx, bson.M{"work_id": id}, update)
+	return mongoError(err)
+}
+
+func (s mongoBookStore) DeleteVersion(ctx context.Context, id int64, version uuid.UUID) error {
+	return mongoTx(ctx, s.db, func(ctx context.Context) error {
+		result, err := s.coll.DeleteOne(ctx, bson.M{"id": id, "version": version})
+		if err != nil {
+			return mongoError(err)
+		}
+
+		if result.DeletedCount == 0 {
+			count, err := s.coll.CountDocuments(ctx, bson.M{"id": id})
+			if err != nil {
+				return mongoError(err)
+			}
+			if count == 0 {
+				return ErrRecordNotFound
+			}
+			return ErrEditConflict
+		}
+		return s.deleted(ctx, id)
+	})
+}
@@ -0,0 +1,197 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+)
+
+type mongoCopyStore struct {
+	db       *mongo.Database
+	coll     *mongo.Collection
+	counters *mongoCounters
+}
+
+func (s mongoCopyStore) Insert(ctx context.Context, c *Copy) error {
+	// The book is locked before the copy is inserted, in one transaction,
+	// so the copy is never left without its book.
+	return mongoTx(ctx, s.db, func(ctx context.Context) error {
+		exists, err := s.lockBook(ctx, c.BookID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRecordNotFound
+		}
+
+		id, err := s.counters.next(ctx, s.db, "copies")
+		if err != nil {
+			return mongoError(err)
+		}
+
+		c.ID = id
+
+		_, err = s.coll.InsertOne(ctx, c)
+		return mongoError(err)
+	})
+}
+
+// lockBook increments the copy_writes counter of a book, which nothing reads
+// and which leaves its version alone. Unlike a read, the write conflicts with
+// a concurrent delete of the book. It reports whether the book exists.
+func (s mongoCopyStore) lockBook(ctx context.Context, bookID int64) (bool, error) {
+	result, err := s.db.Collection("books").UpdateOne(ctx, bson.M{"id": bookID}, bson.M{"$inc": bson.M{"copy_writes": 1}})
+	if err != nil {
+		return false, mongoError(err)
+	}
+	return result.MatchedCount != 0, nil
+}
+
+func (s mongoCopyStore) Get(ctx context.Context, id int64) (*Copy, error) {
+	var c Copy
+
+	err := s.coll.FindOne(ctx, bson.M{"id": id}).Decode(&c)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &c, nil
+}
+
+func (s mongoCopyStore) GetAll(ctx context.Context, filter CopyFilter, filters Filters) ([]*Copy, Metadata, error) {
+	query := bson.M{"book_id": filter.BookID}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Branch != "" {
+		// A regex ignores the collation, which only orders the copies.
+		query["branch"] = bson.M{"$regex": "^" + regexp.QuoteMeta(filter.Branch) + "$", "$options": "i"}
+	}
+
+	totalRecords, err := s.coll.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, Metadata{}, mongoError(err)
+	}
+
+	sort := bson.D{}
+	for _, key := range filters.sortKeys() {
+		sort = append(sort, bson.E{Key: key.column, Value: mongoDirection(!key.descending)})
+	}
+
+	opts := options.Find().
+		SetCollation(mongoCollation).
+		SetSort(sort).
+		SetSkip(int64(filters.offset())).
+		SetLimit(int64(filters.limit()))
+
+	cursor, err := s.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, Metadata{}, mongoError(err)
+	}
+
+	copies := []*Copy{}
+	if err = cursor.All(ctx, &copies); err != nil {
+		return nil, Metadata{}, mongoError(err)
+	}
+
+	return copies, calculateMetadata(int(totalRecords), filters.Page, filters.PageSize), nil
+}
+
+func (s mongoCopyStore) Update(ctx context.Context, c *Copy) error {
+	filter := bson.M{"id": c.ID, "version": c.Version}
+	version := uuid.New()
+
+	update := bson.M{
+		"$set": bson.M{
+			"barcode":   c.Barcode,
+			"branch":    c.Branch,
+			"shelf":     c.Shelf,
+			"condition": c.Condition,
+			"status":    c.Status,
+			"version":   version,
+		},
+	}
+
+	result, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return mongoError(err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrEditConflict
+	}
+
+	c.Version = version
+	return nil
+}
+
+func (s mongoCopyStore) Delete(ctx context.Context, id int64) error {
+	return s.deleteCopy(ctx, bson.M{"id": id})
+}
+
+func (s mongoCopyStore) DeleteVersion(ctx context.Context, id int64, version uuid.UUID) error {
+	err := s.deleteCopy(ctx, bson.M{"id": id, "version": version})
+	if !errors.Is(err, ErrRecordNotFound) {
+		return err
+	}
+
+	count, err := s.coll.CountDocuments(ctx, bson.M{"id": id})
+	if err != nil {
+		return mongoError(err)
+	}
+	if count == 0 {
+		return ErrRecordNotFound
+	}
+	return ErrEditConflict
+}
+
+// deleteCopy deletes the copy matching filter. It returns ErrRecordNotFound
+// if no copy matches.
+func (s mongoCopyStore) deleteCopy(ctx context.Context, filter bson.M) error {
+	result, err := s.coll.DeleteOne(ctx, filter)
+	if err != nil {
+		return mongoError(err)
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (s mongoCopyStore) CountForBooks(ctx context.Context, bookIDs []int64) (map[int64]Availability, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"book_id": bson.M{"$in": bookIDs}}},
+		bson.M{"$group": bson.M{"_id": bson.M{"book_id": "$book_id", "status": "$status"}, "count": bson.M{"$sum": 1}}},
+	}
+
+	cursor, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var groups []struct {
+		ID struct {
+			BookID int64  `bson:"book_id"`
+			Status string `bson:"status"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, mongoError(err)
+	}
+
+	counts := make(map[int64]Availability, len(bookIDs))
+	for _, group := range groups {
+		availability := counts[group.ID.BookID]
+		availability.add(group.ID.Status, group.Count)
+		counts[group.ID.BookID] = availability
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+)
+
+// mongoCounters allocates sequential ids from the counters collection, one
+// document per collection, using an atomic $inc.
+type mongoCounters struct {
+	mu     sync.Mutex
+	seeded map[string]bool
+}
+
+func newMongoCounters() *mongoCounters {
+	return &mongoCounters{seeded: make(map[string]bool)}
+}
+
+func (c *mongoCounters) next(ctx context.Context, db *mongo.Database, name string) (int64, error) {
+	// Counters are bumped outside of any surrounding transaction: a rolled
+	// back insert only leaves a gap, and concurrent transactions don't
+	// conflict on the counter document.
+	ctx = withoutSession(ctx)
+
+	err := c.seed(ctx, db, name)
+	if err != nil {
+		return 0, err
+	}
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	update := bson.M{"$inc": bson.M{"seq": int64(1)}}
+
+	err = db.Collection("counters").FindOneAndUpdate(ctx, bson.M{"_id": name}, update, opts).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+
+	return counter.Seq, nil
+}
+
+// seed makes sure the counter for name starts after the highest id already
+// stored, so collections populated before the counters existed keep working.
+func (c *mongoCounters) seed(ctx context.Context, db *mongo.Database, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seeded[name] {
+		return nil
+	}
+
+	var last struct {
+		ID int64 `bson:"id"`
+	}
+
+	opts := options.FindOne().SetSort(bson.M{"id": -1}).SetProjection(bson.M{"id": 1})
+	err := db.Collection(name).FindOne(ctx, bson.D{}, opts).Decode(&last)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	update := bson.M{"$max": bson.M{"seq": last.ID}}
+	_, err = db.Collection("counters").UpdateOne(ctx, bson.M{"_id": name}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	c.seeded[name] = true
+	return nil
+}
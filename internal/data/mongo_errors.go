@@ -0,0 +1,43 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"regexp"
+)
+
+var mongoDupKeyRX = regexp.MustCompile(`dup key: \{ ?"?(\w+)"?:`)
+
+// mongoError translates a MongoDB driver error into the data package's
+// errors.
+func mongoError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var serverErr mongo.ServerError
+	var selectionErr topology.ServerSelectionError
+
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrRecordNotFound
+	case mongo.IsDuplicateKeyError(err):
+		field := "unknown"
+		if match := mongoDupKeyRX.FindStringSubmatch(err.Error()); match != nil {
+			field = match[1]
+		}
+		return &DuplicateKeyError{Field: field}
+	case errors.As(err, &serverErr) && (serverErr.HasErrorCode(112) || serverErr.HasErrorLabel("TransientTransactionError")):
+		return wrapError(ErrConflict, err)
+	case errors.As(err, &selectionErr) || errors.Is(err, mongo.ErrClientDisconnected):
+		return wrapError(ErrUnavailable, err)
+	case errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err):
+		return wrapError(ErrTimeout, err)
+	case mongo.IsNetworkError(err):
+		return wrapError(ErrUnavailable, err)
+	}
+
+	return err
+}
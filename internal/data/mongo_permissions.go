@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoPermission struct {
+	ID   int64  `bson:"id"`
+	Code string `bson:"code"`
+}
+
+type mongoPermissionStore struct {
+	db *mongo.Database
+}
+
+func (s mongoPermissionStore) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	var links []struct {
+		PermissionID int64 `bson:"permissions_id"`
+	}
+
+	cursor, err := s.db.Collection("user_permissions").Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	if err = cursor.All(ctx, &links); err != nil {
+		return nil, mongoError(err)
+	}
+
+	if len(links) == 0 {
+		return Permissions{}, nil
+	}
+
+	ids := make([]int64, len(links))
+	for i := range links {
+		ids[i] = links[i].PermissionID
+	}
+
+	var found []mongoPermission
+
+	cursor, err = s.db.Collection("permissions").Find(ctx, bson.M{"id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, mongoError(err)
+	}
+
+	permissions := make(Permissions, 0, len(found))
+	for _, permission := range found {
+		permissions = append(permissions, permission.Code)
+	}
+
+	return permissions, nil
+}
+
+func (s mongoPermissionStore) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	var found []mongoPermission
+
+	cursor, err := s.db.Collection("permissions").Find(ctx, bson.M{"code": bson.M{"$in": codes}})
+	if err != nil {
+		return mongoError(err)
+	}
+
+	if err = cursor.All(ctx, &found); err != nil {
+		return mongoError(err)
+	}
+
+	coll := s.db.Collection("user_permissions")
+
+	for _, permission := range found {
+		_, err = coll.InsertOne(ctx, bson.M{"user_id": userID, "permissions_id": permission.ID})
+		if err != nil {
+			return mongoError(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package data
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type mongoTokenStore struct {
+	coll *mongo.Collection
+}
+
+func (s mongoTokenStore) Insert(ctx context.Context, token *Token) error {
+	_, err := s.coll.InsertOne(ctx, bson.M{
+		"user_id": token.UserID,
+		"expiry":  token.Expiry,
+		"scope":   token.Scope,
+		"hash":    token.Hash,
+	})
+	return mongoError(err)
+}
+
+func (s mongoTokenStore) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	_, err := s.coll.DeleteMany(ctx, bson.M{"user_id": userID, "scope": scope})
+	return mongoError(err)
+}
+
+func (s mongoTokenStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := s.coll.DeleteMany(ctx, bson.M{"expiry": bson.M{"$lte": now}})
+	if err != nil {
+		return 0, mongoError(err)
+	}
+
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// mongoUser is the document shape of the users collection. The password
+// hash is unexported on User, so it cannot be encoded directly.
+type mongoUser struct {
+	ID        int64     `bson:"id"`
+	CreatedAt time.Time `bson:"created_at"`
+	Name      string    `bson:"name"`
+	Email     string    `bson:"email"`
+	Password  []byte    `bson:"password"`
+	Activated bool      `bson:"activated"`
+	Version   uuid.UUID `bson:"version"`
+}
+
+func (u mongoUser) user() *User {
+	return &User{
+		ID:        u.ID,
+		CreatedAt: u.CreatedAt,
+		Name:      u.Name,
+		Email:     u.Email,
+		Password:  password{hash: u.Password},
+		Activated: u.Activated,
+		Version:   u.Version,
+	}
+}
+
+type mongoUserStore struct {
+	db       *mongo.Database
+	counters *mongoCounters
+}
+
+func (s mongoUserStore) Insert(ctx context.Context, user *User) error {
+	id, err := s.counters.next(ctx, s.db, "users")
+	if err != nil {
+		return mongoError(err)
+	}
+
+	user.ID = id
+
+	_, err = s.db.Collection("users").InsertOne(ctx, mongoUser{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		Name:      user.Name,
+		Email:     user.Email,
+		Password:  user.Password.hash,
+		Activated: user.Activated,
+		Version:   user.Version,
+	})
+	return mongoError(err)
+}
+
+func (s mongoUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	var doc mongoUser
+
+	err := s.db.Collection("users").FindOne(ctx, bson.M{"email": email}).Decode(&doc)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return doc.user(), nil
+}
+
+func (s mongoUserStore) GetForToken(ctx context.Context, scope string, hash []byte, now time.Time) (*User, error) {
+	var token struct {
+		UserID int64 `bson:"user_id"`
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"_id": 0, "user_id": 1})
+	err := s.db.Collection("tokens").FindOne(ctx, bson.M{"hash": hash, "scope": scope, "expiry": bson.M{"$gt": now}}, opts).Decode(&token)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var doc mongoUser
+
+	err = s.db.Collection("users").FindOne(ctx, bson.M{"id": token.UserID}).Decode(&doc)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return doc.user(), nil
+}
+
+func (s mongoUserStore) Update(ctx context.Context, user *User) error {
+	filter := bson.M{"id": user.ID, "version": user.Version}
+	version := uuid.New()
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":      user.Name,
+			"email":     user.Email,
+			"password":  user.Password.hash,
+			"activated": user.Activated,
+			"version":   version,
+		},
+	}
+
+	result, err := s.db.Collection("users").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return mongoError(err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrEditConflict
+	}
+
+	user.Version = version
+	return nil
+}
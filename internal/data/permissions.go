@@ -17,30 +17,20 @@ func (p Permissions) Include(code string) bool {
 }
 
 type PermissionModel struct {
-	DB DB
+	Store   PermissionStore
+	Timeout time.Duration
 }
 
-func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m PermissionModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	res, err := m.DB.Get(ctx, "", userID, "permissions", "")
-	if err != nil {
-		return nil, err
-	}
-
-	return res.(Permissions), nil
+	return m.Store.GetAllForUser(ctx, userID)
 }
 
-type UserPermissionsSend struct {
-	User_Id int64    `json:"user_id"`
-	Codes   []string `json:"codes"`
-}
-
-func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m PermissionModel) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	return m.DB.Insert(ctx, "", "user_permissions", UserPermissionsSend{User_Id: userID, Codes: codes})
-
+	return m.Store.AddForUser(ctx, userID, codes...)
 }
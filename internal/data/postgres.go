@@ -2,34 +2,59 @@ package data
 
 import (
 	"context"
-	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Postgres struct {
 	DB *pgxpool.Pool
+	tx pgx.Tx
 }
 
-func (m *Postgres) Insert(ctx context.Context, query string, collection string, data interface{}) error {
-	if collection == "books" {
-		book := data.(*Book)
-		args := []any{book.Title, book.Author, book.Year, book.Size, book.Genres, book.Version}
-		return m.DB.QueryRow(ctx, query, args).Scan(&book.ID)
+// pgQuerier is satisfied by both the connection pool and a transaction, so
+// stores run the same queries inside and outside of WithTx.
+type pgQuerier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (m *Postgres) querier() pgQuerier {
+	if m.tx != nil {
+		return m.tx
 	}
-	return errors.New("No collections in database")
+	return m.DB
 }
-func (m *Postgres) Get(ctx context.Context, query string, id interface{}, collection string, scope string) (interface{}, error) {
-	return nil, nil
+
+func (m *Postgres) Books() BookStore {
+	return postgresBookStore{db: m.querier()}
 }
-func (m *Postgres) GetAll(ctx context.Context, query string, collection string, opt map[string]string, genres []string, filters Filters) ([]*Book, Metadata, error) {
-	return nil, Metadata{}, nil
+
+func (m *Postgres) Authors() AuthorStore {
+	return postgresAuthorStore{db: m.querier()}
 }
-func (m *Postgres) Update(ctx context.Context, query string, data interface{}) error {
-	return nil
+
+func (m *Postgres) Copies() CopyStore {
+	return postgresCopyStore{db: m.querier()}
 }
-func (m *Postgres) Delete(ctx context.Context, query string, id int64, collection string, scope string) error {
-	return nil
+
+func (m *Postgres) Users() UserStore {
+	return postgresUserStore{db: m.querier()}
 }
-func (m *Postgres) GetLastId(ctx context.Context, query string, collection string) (int64, error) {
-	return 0, nil
+
+func (m *Postgres) Tokens() TokenStore {
+	return postgresTokenStore{db: m.querier()}
+}
+
+func (m *Postgres) Permissions() PermissionStore {
+	return postgresPermissionStore{db: m.querier()}
+}
+
+func (m *Postgres) WithTx(ctx context.Context, fn func(ctx context.Context, tx DB) error) error {
+	err := pgx.BeginFunc(ctx, m.querier(), func(tx pgx.Tx) error {
+		return fn(ctx, &Postgres{DB: m.DB, tx: tx})
+	})
+	return postgresError(err)
 }
@@ -0,0 +1,250 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"strings"
+)
+
+type postgresAuthorStore struct {
+	db pgQuerier
+}
+
+func (s postgresAuthorStore) Insert(ctx context.Context, author *Author) error {
+	query := `
+		INSERT INTO authors (name, version)
+		VALUES ($1, $2)
+		RETURNING id, created_at`
+
+	err := s.db.QueryRow(ctx, query, author.Name, author.Version).Scan(&author.ID, &author.CreatedAt)
+	return postgresError(err)
+}
+
+func (s postgresAuthorStore) Get(ctx context.Context, id int64) (*Author, error) {
+	query := `
+		SELECT id, created_at, name, version
+		FROM authors
+		WHERE id = $1`
+
+	var author Author
+
+	err := s.db.QueryRow(ctx, query, id).Scan(&author.ID, &author.CreatedAt, &author.Name, &author.Version)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+
+	return &author, nil
+}
+
+func (s postgresAuthorStore) GetAll(ctx context.Context, name string, filters Filters) ([]*Author, Metadata, error) {
+	var totalRecords int
+
+	err := s.db.QueryRow(ctx, `SELECT count(*) FROM authors WHERE name ~* $1`, authorNamePattern(name)).Scan(&totalRecords)
+	if err != nil {
+		return nil, Metadata{}, postgresError(err)
+	}
+
+	keys := filters.sortKeys()
+
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		expr := key.column
+		if expr == "name" {
+			expr = `name COLLATE "books_ci"`
+		}
+		order[i] = expr + " " + postgresDirection(!key.descending)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, created_at, name, version
+		FROM authors
+		WHERE name ~* $1
+		ORDER BY %s
+		LIMIT $2 OFFSET $3`, strings.Join(order, ", "))
+
+	rows, err := s.db.Query(ctx, query, authorNamePattern(name), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, postgresError(err)
+	}
+	defer rows.Close()
+
+	authors := []*Author{}
+
+	for rows.Next() {
+		var author Author
+
+		err := rows.Scan(&author.ID, &author.CreatedAt, &author.Name, &author.Version)
+		if err != nil {
+			return nil, Metadata{}, postgresError(err)
+		}
+
+		authors = append(authors, &author)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, postgresError(err)
+	}
+
+	return authors, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (s postgresAuthorStore) Update(ctx context.Context, author *Author) error {
+	query := `
+		UPDATE authors
+		SET name = $1, version = $2
+		WHERE id = $3 AND version = $4
+		RETURNING version`
+
+	args := []any{author.Name, uuid.New(), author.ID, author.Version}
+
+	err := postgresError(s.db.QueryRow(ctx, query, args...).Scan(&author.Version))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the author, and through the cascade its links to books. The
+// linked books get a new version in the same statement, since their authors
+// changed.
+func (s postgresAuthorStore) Delete(ctx context.Context, id int64) error {
+	query := `
+		WITH linked AS (
+			UPDATE books
+			SET version = $2
+			WHERE id IN (SELECT book_id FROM books_authors WHERE author_id = $1)
+		)
+		DELETE FROM authors
+		WHERE id = $1`
+
+	result, err := s.db.Exec(ctx, query, id, uuid.New())
+	if err != nil {
+		return postgresError(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (s postgresAuthorStore) GetForBooks(ctx context.Context, bookIDs []int64) (map[int64][]BookAuthor, error) {
+	query := `
+		SELECT books_authors.book_id, books_authors.author_id, authors.name, books_authors.role
+		FROM books_authors
+		INNER JOIN authors
+		ON authors.id = books_authors.author_id
+		WHERE books_authors.book_id = ANY($1)
+		ORDER BY books_authors.book_id, books_authors.position`
+
+	rows, err := s.db.Query(ctx, query, bookIDs)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	defer rows.Close()
+
+	links := make(map[int64][]BookAuthor, len(bookIDs))
+
+	for rows.Next() {
+		var bookID int64
+		var link BookAuthor
+
+		err := rows.Scan(&bookID, &link.AuthorID, &link.Name, &link.Role)
+		if err != nil {
+			return nil, postgresError(err)
+		}
+
+		links[bookID] = append(links[bookID], link)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, postgresError(err)
+	}
+
+	return links, nil
+}
+
+func (s postgresAuthorStore) SetForBook(ctx context.Context, bookID int64, authors []BookAuthor) error {
+	ids := make([]int64, len(authors))
+	for i, author := range authors {
+		ids[i] = author.AuthorID
+	}
+
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		// Locking the book keeps concurrent updates of its authors apart.
+		err := tx.QueryRow(ctx, `SELECT id FROM books WHERE id = $1 FOR UPDATE`, bookID).Scan(&bookID)
+		if err != nil {
+			return err
+		}
+
+		var missing bool
+		query := `
+			SELECT EXISTS (
+				SELECT 1
+				FROM unnest($1::bigint[]) AS ids(id)
+				WHERE NOT EXISTS (SELECT 1 FROM authors WHERE authors.id = ids.id)
+			)`
+		err = tx.QueryRow(ctx, query, ids).Scan(&missing)
+		if err != nil {
+			return err
+		}
+		if missing {
+			return ErrUnknownAuthor
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM books_authors WHERE book_id = $1`, bookID)
+		if err != nil {
+			return err
+		}
+
+		for i, author := range authors {
+			query := `
+				INSERT INTO books_authors (book_id, author_id, role, position)
+				VALUES ($1, $2, $3, $4)`
+
+			_, err := tx.Exec(ctx, query, bookID, author.AuthorID, author.Role, i)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return postgresError(err)
+}
+
+func (s postgresAuthorStore) LinkByName(ctx context.Context, bookID int64, name string) error {
+	query := `
+		INSERT INTO books_authors (book_id, author_id, role, position)
+		SELECT $1, id, 'author', 0
+		FROM authors
+		WHERE name = $2
+		AND NOT EXISTS (SELECT 1 FROM books_authors WHERE book_id = $1)
+		ORDER BY id
+		LIMIT 1`
+
+	_, err := s.db.Exec(ctx, query, bookID, name)
+	return postgresError(err)
+}
+
+func (s postgresAuthorStore) ExistsByName(ctx context.Context, name string) (bool, error) {
+	var exists bool
+
+	err := s.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM authors WHERE name = $1)`, name).Scan(&exists)
+	if err != nil {
+		return false, postgresError(err)
+	}
+
+	return exists, nil
+}
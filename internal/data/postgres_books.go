@@ -0,0 +1,543 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+)
+
+type postgresBookStore struct {
+	db pgQuerier
+}
+
+func (s postgresBookStore) Insert(ctx context.Context, book *Book) error {
+	// The id is drawn first so that a book starting a new work can use it
+	// as its work_id.
+	query := `
+		WITH next AS (
+			SELECT nextval(pg_get_serial_sequence('books', 'id')) AS id
+		)
+		INSERT INTO books (id, title, author, year, size, genres, isbn_10, isbn_13, work_id, format, duration, publisher, version)
+		SELECT id, $1::text, $2::text, $3::integer, $4::integer, $5::text[], $6::text, $7::text,
+			COALESCE(NULLIF($8::bigint, 0), id), $9::text, $10::integer, $11::text, $12::uuid
+		FROM next
+		RETURNING id, created_at, work_id`
+
+	args := []any{book.Title, book.Author, book.Year, book.Size, book.Genres, book.ISBN10, book.ISBN13, book.WorkID, book.Format, book.Duration, book.Publisher, book.Version}
+
+	err := s.db.QueryRow(ctx, query, args...).Scan(&book.ID, &book.CreatedAt, &book.WorkID)
+	return postgresError(err)
+}
+
+// postgresBookColumns lists the columns of the books table in select order,
+// with the Book field each one scans into.
+var postgresBookColumns = []struct {
+	name string
+	dest func(book *Book) any
+}{
+	{"id", func(book *Book) any { return &book.ID }},
+	{"created_at", func(book *Book) any { return &book.CreatedAt }},
+	{"title", func(book *Book) any { return &book.Title }},
+	{"author", func(book *Book) any { return &book.Author }},
+	{"year", func(book *Book) any { return &book.Year }},
+	{"size", func(book *Book) any { return &book.Size }},
+	{"genres", func(book *Book) any { return &book.Genres }},
+	{"isbn_10", func(book *Book) any { return &book.ISBN10 }},
+	{"isbn_13", func(book *Book) any { return &book.ISBN13 }},
+	{"work_id", func(book *Book) any { return &book.WorkID }},
+	{"format", func(book *Book) any { return &book.Format }},
+	{"duration", func(book *Book) any { return &book.Duration }},
+	{"publisher", func(book *Book) any { return &book.Publisher }},
+	{"version", func(book *Book) any { return &book.Version }},
+}
+
+// postgresBookSelect returns the select list for projection, and a function
+// giving the scan destinations in the same order.
+func postgresBookSelect(projection []string) (string, func(book *Book) []any) {
+	var names []string
+	var dests []func(book *Book) any
+
+	for _, column := range postgresBookColumns {
+		if selectsField(projection, column.name) {
+			names = append(names, column.name)
+			dests = append(dests, column.dest)
+		}
+	}
+
+	return strings.Join(names, ", "), func(book *Book) []any {
+		args := make([]any, len(dests))
+		for i, dest := range dests {
+			args[i] = dest(book)
+		}
+		return args
+	}
+}
+
+func (s postgresBookStore) Get(ctx context.Context, id int64, fields ...string) (*Book, error) {
+	columns, dest := postgresBookSelect(fields)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM books
+		WHERE id = $1`, columns)
+
+	var book Book
+
+	err := s.db.QueryRow(ctx, query, id).Scan(dest(&book)...)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+
+	return &book, nil
+}
+
+func (s postgresBookStore) GetByISBN(ctx context.Context, isbn13 string) (*Book, error) {
+	columns, dest := postgresBookSelect(nil)
+
+	// Books without an ISBN store an empty string, which the partial unique
+	// index leaves out; repeating its predicate lets the planner use it.
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM books
+		WHERE isbn_13 = $1 AND isbn_13 <> ''`, columns)
+
+	var book Book
+
+	err := s.db.QueryRow(ctx, query, isbn13).Scan(dest(&book)...)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+
+	return &book, nil
+}
+
+// postgresWhere accumulates the conditions of a WHERE clause together with
+// their arguments, numbering placeholders in the order they are added.
+type postgresWhere struct {
+	conditions []string
+	args       []any
+}
+
+// add appends a condition. The verbs in format are replaced with the
+// placeholder numbers of args, so %[1]d can repeat the first argument.
+func (w *postgresWhere) add(format string, args ...any) {
+	placeholders := make([]any, len(args))
+	for i, arg := range args {
+		w.args = append(w.args, arg)
+		placeholders[i] = len(w.args)
+	}
+	w.conditions = append(w.conditions, fmt.Sprintf(format, placeholders...))
+}
+
+// placeholder adds arg and returns its placeholder, for conditions that are
+// assembled piece by piece.
+func (w *postgresWhere) placeholder(arg any) string {
+	w.args = append(w.args, arg)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+func (w *postgresWhere) String() string {
+	if len(w.conditions) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(w.conditions, " AND ")
+}
+
+// postgresBookFilter builds the WHERE clause shared by the count and the
+// select of a book listing.
+func postgresBookFilter(f BookFilter) *postgresWhere {
+	where := &postgresWhere{}
+	if f.Title != "" {
+		where.add("title ~* $%d", f.pattern(f.Title))
+	}
+	if f.Author != "" {
+		where.add("author ~* $%d", f.pattern(f.Author))
+	}
+	if len(f.Genres) != 0 {
+		where.add("genres @> $%d", f.Genres)
+	}
+	if f.Expr != nil {
+		where.conditions = append(where.conditions, postgresFilterExpr(where, f.Expr))
+	}
+	if f.Search != nil {
+		where.add("search @@ to_tsquery('simple', $%d)", postgresTextSearch(f.Search))
+	}
+	if f.AuthorID != 0 {
+		where.add("id IN (SELECT book_id FROM books_authors WHERE author_id = $%d)", f.AuthorID)
+	}
+	if f.WorkIDs != nil {
+		where.add("work_id = ANY($%d)", f.WorkIDs)
+	}
+	if f.FirstEditions {
+		where.conditions = append(where.conditions, "id = work_id")
+	}
+	return where
+}
+
+// postgresTextSearch renders s as a to_tsquery expression. searchTokens only
+// yields letters and digits, so the words need no quoting.
+func postgresTextSearch(s *TextSearch) string {
+	var parts []string
+
+	parts = append(parts, s.Terms...)
+	for _, prefix := range s.Prefixes {
+		parts = append(parts, prefix+":*")
+	}
+	for _, phrase := range s.Phrases {
+		parts = append(parts, "("+strings.Join(phrase, " <-> ")+")")
+	}
+
+	return strings.Join(parts, " & ")
+}
+
+// postgresFilterExpr compiles e into a SQL condition, adding its values to
+// where as arguments. Field names come from data.FilterFields, never from
+// user input, so they are safe to interpolate.
+func postgresFilterExpr(where *postgresWhere, e FilterExpr) string {
+	switch e := e.(type) {
+	case FilterAnd:
+		return postgresJoinFilterExprs(where, e.Exprs, " AND ")
+	case FilterOr:
+		return postgresJoinFilterExprs(where, e.Exprs, " OR ")
+	case FilterNot:
+		return "NOT " + postgresFilterExpr(where, e.Expr)
+	case FilterCondition:
+		switch e.Op {
+		case FilterAny:
+			return fmt.Sprintf("(genres && %s)", where.placeholder(e.Value))
+		case FilterAll:
+			return fmt.Sprintf("(genres @> %s)", where.placeholder(e.Value))
+		case FilterNe:
+			return fmt.Sprintf("(%s <> %s)", e.Field, where.placeholder(e.Value))
+		default:
+			return fmt.Sprintf("(%s %s %s)", e.Field, e.Op, where.placeholder(e.Value))
+		}
+	default:
+		panic("unknown filter expression")
+	}
+}
+
+func postgresJoinFilterExprs(where *postgresWhere, exprs []FilterExpr, sep string) string {
+	conditions := make([]string, len(exprs))
+	for i, expr := range exprs {
+		conditions[i] = postgresFilterExpr(where, expr)
+	}
+	return "(" + strings.Join(conditions, sep) + ")"
+}
+
+func postgresDirection(ascending bool) string {
+	if ascending {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+func postgresComparison(ascending bool) string {
+	if ascending {
+		return ">"
+	}
+	return "<"
+}
+
+// postgresSortExpr returns the expression a sort column orders by. Text
+// columns use the case-insensitive books_ci collation from the migrations.
+func postgresSortExpr(column string) string {
+	switch column {
+	case "title", "author":
+		return column + ` COLLATE "books_ci"`
+	default:
+		return column
+	}
+}
+
+// postgresKeyset builds the condition selecting the books after values in
+// the order of keys: the first key is past its value, or equal with the
+// second key past its value, and so on.
+func postgresKeyset(where *postgresWhere, keys []sortKey, values []any) string {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = where.placeholder(value)
+	}
+
+	alternatives := make([]string, len(keys))
+	for i, key := range keys {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, fmt.Sprintf("%s = %s", postgresSortExpr(keys[j].column), placeholders[j]))
+		}
+		terms = append(terms, fmt.Sprintf("%s %s %s", postgresSortExpr(key.column), postgresComparison(!key.descending), placeholders[i]))
+		alternatives[i] = "(" + strings.Join(terms, " AND ") + ")"
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
+
+func (s postgresBookStore) GetAll(ctx context.Context, filter BookFilter, filters Filters) ([]*Book, Metadata, error) {
+	c, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	where := postgresBookFilter(filter)
+
+	var totalRecords int
+
+	if !filters.SkipCount {
+		countQuery := fmt.Sprintf(`
+			SELECT count(*)
+			FROM books
+			WHERE %s`, where)
+
+		err = s.db.QueryRow(ctx, countQuery, where.args...).Scan(&totalRecords)
+		if err != nil {
+			return nil, Metadata{}, postgresError(err)
+		}
+	}
+
+	// The rank is only added to the arguments after the count, which does
+	// not reference it.
+	relevance := "0::real"
+	if filter.Search != nil {
+		relevance = fmt.Sprintf("ts_rank(search, to_tsquery('simple', %s))", where.placeholder(postgresTextSearch(filter.Search)))
+	}
+
+	keys := filters.walkKeys(c)
+
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		order[i] = postgresSortExpr(key.column) + " " + postgresDirection(!key.descending)
+	}
+
+	limit, offset := filters.fetchLimit(c), filters.offset()
+	if c != nil {
+		where.conditions = append(where.conditions, postgresKeyset(where, keys, c.Values))
+		offset = 0
+	}
+
+	columns, dest := postgresBookSelect(bookProjection(filters.Fields, filters.sortColumns()...))
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s AS relevance
+		FROM books
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d`, columns, relevance, where, strings.Join(order, ", "), len(where.args)+1, len(where.args)+2)
+
+	args := append(where.args, limit, offset)
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, postgresError(err)
+	}
+	defer rows.Close()
+
+	books := []*Book{}
+
+	for rows.Next() {
+		var book Book
+
+		err := rows.Scan(append(dest(&book), &book.Score)...)
+		if err != nil {
+			return nil, Metadata{}, postgresError(err)
+		}
+
+		books = append(books, &book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, postgresError(err)
+	}
+
+	return paginateBooks(books, totalRecords, filters)
+}
+
+// postgresFacetQueries holds the GROUP BY query of each facet. The %s verb
+// receives the WHERE clause of the listing.
+var postgresFacetQueries = map[string]string{
+	"genres": `
+		SELECT genre, count(*)
+		FROM books, unnest(genres) AS genre
+		WHERE %s
+		GROUP BY genre
+		ORDER BY count(*) DESC, genre ASC
+		LIMIT 20`,
+	"year": `
+		SELECT year, count(*)
+		FROM books
+		WHERE %s
+		GROUP BY year
+		ORDER BY year ASC`,
+	"author": `
+		SELECT author, count(*)
+		FROM books
+		WHERE %s
+		GROUP BY author
+		ORDER BY count(*) DESC, author ASC
+		LIMIT 20`,
+}
+
+func (s postgresBookStore) Facets(ctx context.Context, filter BookFilter, fields []string) (Facets, error) {
+	where := postgresBookFilter(filter)
+
+	facets := make(Facets, len(fields))
+
+	for _, field := range fields {
+		query := fmt.Sprintf(postgresFacetQueries[field], where)
+
+		rows, err := s.db.Query(ctx, query, where.args...)
+		if err != nil {
+			return nil, postgresError(err)
+		}
+
+		facet := []FacetCount{}
+
+		for rows.Next() {
+			var count FacetCount
+
+			err := rows.Scan(&count.Value, &count.Count)
+			if err != nil {
+				rows.Close()
+				return nil, postgresError(err)
+			}
+
+			facet = append(facet, count)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return nil, postgresError(err)
+		}
+
+		facets[field] = facet
+	}
+
+	return facets, nil
+}
+
+// postgresSuggestQueries holds the autocomplete query of each field. The
+// title and author queries can use the lower(...) text_pattern_ops indexes.
+var postgresSuggestQueries = map[string]string{
+	"title": `
+		SELECT title, count(*)
+		FROM books
+		WHERE lower(title) LIKE $1
+		GROUP BY title
+		ORDER BY count(*) DESC, title ASC
+		LIMIT $2`,
+	"author": `
+		SELECT author, count(*)
+		FROM books
+		WHERE lower(author) LIKE $1
+		GROUP BY author
+		ORDER BY count(*) DESC, author ASC
+		LIMIT $2`,
+	"genres": `
+		SELECT genre, count(*)
+		FROM books, unnest(genres) AS genre
+		WHERE lower(genre) LIKE $1
+		GROUP BY genre
+		ORDER BY count(*) DESC, genre ASC
+		LIMIT $2`,
+}
+
+var postgresLikeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (s postgresBookStore) Suggest(ctx context.Context, field string, prefix string, limit int) ([]Suggestion, error) {
+	pattern := postgresLikeEscaper.Replace(strings.ToLower(prefix)) + "%"
+
+	rows, err := s.db.Query(ctx, postgresSuggestQueries[field], pattern, limit)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+
+	for rows.Next() {
+		var suggestion Suggestion
+
+		err := rows.Scan(&suggestion.Value, &suggestion.Count)
+		if err != nil {
+			return nil, postgresError(err)
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, postgresError(err)
+	}
+
+	return suggestions, nil
+}
+
+func (s postgresBookStore) Update(ctx context.Context, book *Book) error {
+	query := `
+		UPDATE books
+		SET title = $1, author = $2, year = $3, size = $4, genres = $5, isbn_10 = $6, isbn_13 = $7,
+			format = $8, duration = $9, publisher = $10, version = $11
+		WHERE id = $12 AND version = $13
+		RETURNING version`
+
+	args := []any{book.Title, book.Author, book.Year, book.Size, book.Genres, book.ISBN10, book.ISBN13, book.Format, book.Duration, book.Publisher, uuid.New(), book.ID, book.Version}
+
+	err := postgresError(s.db.QueryRow(ctx, query, args...).Scan(&book.Version))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteBook deletes the book with id $1 if it also matches condition, and
+// in the same statement moves the work it was the first edition of to the
+// next edition, giving the moved editions a new version. It returns ErrRecordNotFound if there is no such book, and
+// ErrEditConflict if the book exists but does not match condition.
+func (s postgresBookStore) deleteBook(ctx context.Context, condition string, args ...any) error {
+	query := fmt.Sprintf(`
+		WITH deleted AS (
+			DELETE FROM books
+			WHERE id = $1 AND %s
+			RETURNING id
+		), moved AS (
+			UPDATE books
+			SET work_id = (SELECT min(id) FROM books WHERE work_id = $1 AND id <> $1), version = $%d
+			WHERE work_id = $1 AND id <> $1 AND EXISTS (SELECT 1 FROM deleted)
+		)
+		SELECT EXISTS (SELECT 1 FROM deleted), EXISTS (SELECT 1 FROM books WHERE id = $1)`, condition, len(args)+1)
+
+	args = append(args, uuid.New())
+
+	// The statement sees the books as they were before the delete, so the
+	// second column tells whether the book existed at all.
+	var deleted, existed bool
+
+	err := s.db.QueryRow(ctx, query, args...).Scan(&deleted, &existed)
+	if err != nil {
+		return postgresError(err)
+	}
+
+	switch {
+	case deleted:
+		return nil
+	case existed:
+		return ErrEditConflict
+	default:
+		return ErrRecordNotFound
+	}
+}
+
+func (s postgresBookStore) Delete(ctx context.Context, id int64) error {
+	return s.deleteBook(ctx, "TRUE", id)
+}
+
+func (s postgresBookStore) DeleteVersion(ctx context.Context, id int64, version uuid.UUID) error {
+	return s.deleteBook(ctx, "version = $2", id, version)
+}
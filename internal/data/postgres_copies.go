@@ -0,0 +1,198 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+)
+
+type postgresCopyStore struct {
+	db pgQuerier
+}
+
+func (s postgresCopyStore) Insert(ctx context.Context, c *Copy) error {
+	// Selecting the book makes the insert fail with ErrRecordNotFound when
+	// it does not exist, rather than with a foreign key violation.
+	query := `
+		INSERT INTO copies (book_id, barcode, branch, shelf, condition, status, version)
+		SELECT id, $2, $3, $4, $5, $6, $7
+		FROM books
+		WHERE id = $1
+		RETURNING id, created_at`
+
+	args := []any{c.BookID, c.Barcode, c.Branch, c.Shelf, c.Condition, c.Status, c.Version}
+
+	err := s.db.QueryRow(ctx, query, args...).Scan(&c.ID, &c.CreatedAt)
+	return postgresError(err)
+}
+
+func (s postgresCopyStore) Get(ctx context.Context, id int64) (*Copy, error) {
+	query := `
+		SELECT id, book_id, created_at, barcode, branch, shelf, condition, status, version
+		FROM copies
+		WHERE id = $1`
+
+	var c Copy
+
+	err := s.db.QueryRow(ctx, query, id).Scan(&c.ID, &c.BookID, &c.CreatedAt, &c.Barcode, &c.Branch, &c.Shelf, &c.Condition, &c.Status, &c.Version)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+
+	return &c, nil
+}
+
+func (s postgresCopyStore) GetAll(ctx context.Context, filter CopyFilter, filters Filters) ([]*Copy, Metadata, error) {
+	where := `
+		WHERE book_id = $1
+		AND (status = $2 OR $2 = '')
+		AND (lower(branch) = lower($3) OR $3 = '')`
+
+	args := []any{filter.BookID, filter.Status, filter.Branch}
+
+	var totalRecords int
+
+	err := s.db.QueryRow(ctx, `SELECT count(*) FROM copies`+where, args...).Scan(&totalRecords)
+	if err != nil {
+		return nil, Metadata{}, postgresError(err)
+	}
+
+	keys := filters.sortKeys()
+
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		expr := key.column
+		if expr == "barcode" || expr == "branch" {
+			expr += ` COLLATE "books_ci"`
+		}
+		order[i] = expr + " " + postgresDirection(!key.descending)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, book_id, created_at, barcode, branch, shelf, condition, status, version
+		FROM copies %s
+		ORDER BY %s
+		LIMIT $4 OFFSET $5`, where, strings.Join(order, ", "))
+
+	rows, err := s.db.Query(ctx, query, append(args, filters.limit(), filters.offset())...)
+	if err != nil {
+		return nil, Metadata{}, postgresError(err)
+	}
+	defer rows.Close()
+
+	copies := []*Copy{}
+
+	for rows.Next() {
+		var c Copy
+
+		err := rows.Scan(&c.ID, &c.BookID, &c.CreatedAt, &c.Barcode, &c.Branch, &c.Shelf, &c.Condition, &c.Status, &c.Version)
+		if err != nil {
+			return nil, Metadata{}, postgresError(err)
+		}
+
+		copies = append(copies, &c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, postgresError(err)
+	}
+
+	return copies, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (s postgresCopyStore) Update(ctx context.Context, c *Copy) error {
+	query := `
+		UPDATE copies
+		SET barcode = $1, branch = $2, shelf = $3, condition = $4, status = $5, version = $6
+		WHERE id = $7 AND version = $8
+		RETURNING version`
+
+	args := []any{c.Barcode, c.Branch, c.Shelf, c.Condition, c.Status, uuid.New(), c.ID, c.Version}
+
+	err := postgresError(s.db.QueryRow(ctx, query, args...).Scan(&c.Version))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteCopy deletes the copy with id $1 if it also matches condition. It
+// returns ErrRecordNotFound if there is no such copy, and ErrEditConflict if
+// the copy exists but does not match condition.
+func (s postgresCopyStore) deleteCopy(ctx context.Context, condition string, args ...any) error {
+	query := fmt.Sprintf(`
+		WITH deleted AS (
+			DELETE FROM copies
+			WHERE id = $1 AND %s
+			RETURNING id
+		)
+		SELECT EXISTS (SELECT 1 FROM deleted), EXISTS (SELECT 1 FROM copies WHERE id = $1)`, condition)
+
+	var deleted, existed bool
+
+	err := s.db.QueryRow(ctx, query, args...).Scan(&deleted, &existed)
+	if err != nil {
+		return postgresError(err)
+	}
+
+	switch {
+	case deleted:
+		return nil
+	case existed:
+		return ErrEditConflict
+	default:
+		return ErrRecordNotFound
+	}
+}
+
+func (s postgresCopyStore) Delete(ctx context.Context, id int64) error {
+	return s.deleteCopy(ctx, "TRUE", id)
+}
+
+func (s postgresCopyStore) DeleteVersion(ctx context.Context, id int64, version uuid.UUID) error {
+	return s.deleteCopy(ctx, "version = $2", id, version)
+}
+
+func (s postgresCopyStore) CountForBooks(ctx context.Context, bookIDs []int64) (map[int64]Availability, error) {
+	query := `
+		SELECT book_id, status, count(*)
+		FROM copies
+		WHERE book_id = ANY($1)
+		GROUP BY book_id, status`
+
+	rows, err := s.db.Query(ctx, query, bookIDs)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]Availability, len(bookIDs))
+
+	for rows.Next() {
+		var bookID int64
+		var status string
+		var n int
+
+		if err := rows.Scan(&bookID, &status, &n); err != nil {
+			return nil, postgresError(err)
+		}
+
+		availability := counts[bookID]
+		availability.add(status, n)
+		counts[bookID] = availability
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, postgresError(err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"net"
+	"strings"
+)
+
+// postgresUniqueFields names the field behind each unique constraint, for
+// DuplicateKeyError. Unlisted constraints are reported by name.
+var postgresUniqueFields = map[string]string{
+	"books_pkey":             "id",
+	"books_isbn_13_key":      "isbn_13",
+	"copies_barcode_key":     "barcode",
+	"users_pkey":             "id",
+	"users_email_key":        "email",
+	"tokens_pkey":            "hash",
+	"permissions_code_key":   "code",
+	"users_permissions_pkey": "permission",
+}
+
+// postgresError translates a pgx error into the data package's errors.
+func postgresError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pgErr *pgconn.PgError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrRecordNotFound
+	case errors.As(err, &pgErr):
+		switch {
+		case pgErr.Code == "23505":
+			field, ok := postgresUniqueFields[pgErr.ConstraintName]
+			if !ok {
+				field = pgErr.ConstraintName
+			}
+			return &DuplicateKeyError{Field: field}
+		case pgErr.Code == "40001" || pgErr.Code == "40P01":
+			return wrapError(ErrConflict, err)
+		case pgErr.Code == "57014":
+			return wrapError(ErrTimeout, err)
+		case strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P") || pgErr.Code == "53300":
+			return wrapError(ErrUnavailable, err)
+		}
+	case errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err):
+		return wrapError(ErrTimeout, err)
+	case pgconn.SafeToRetry(err) || errors.As(err, &netErr):
+		return wrapError(ErrUnavailable, err)
+	}
+
+	return err
+}
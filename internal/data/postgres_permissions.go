@@ -0,0 +1,50 @@
+package data
+
+import (
+	"context"
+)
+
+type postgresPermissionStore struct {
+	db pgQuerier
+}
+
+func (s postgresPermissionStore) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	query := `
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		WHERE users_permissions.user_id = $1`
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+
+	for rows.Next() {
+		var permission string
+
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, postgresError(err)
+		}
+
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, postgresError(err)
+	}
+
+	return permissions, nil
+}
+
+func (s postgresPermissionStore) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	query := `
+		INSERT INTO users_permissions
+		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
+
+	_, err := s.db.Exec(ctx, query, userID, codes)
+	return postgresError(err)
+}
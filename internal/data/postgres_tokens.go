@@ -0,0 +1,43 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+type postgresTokenStore struct {
+	db pgQuerier
+}
+
+func (s postgresTokenStore) Insert(ctx context.Context, token *Token) error {
+	query := `
+		INSERT INTO tokens (hash, user_id, expiry, scope)
+		VALUES ($1, $2, $3, $4)`
+
+	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
+
+	_, err := s.db.Exec(ctx, query, args...)
+	return postgresError(err)
+}
+
+func (s postgresTokenStore) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	query := `
+		DELETE FROM tokens
+		WHERE scope = $1 AND user_id = $2`
+
+	_, err := s.db.Exec(ctx, query, scope, userID)
+	return postgresError(err)
+}
+
+func (s postgresTokenStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	query := `
+		DELETE FROM tokens
+		WHERE expiry <= $1`
+
+	result, err := s.db.Exec(ctx, query, now)
+	if err != nil {
+		return 0, postgresError(err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,88 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+type postgresUserStore struct {
+	db pgQuerier
+}
+
+func (s postgresUserStore) Insert(ctx context.Context, user *User) error {
+	query := `
+		INSERT INTO users (name, email, password_hash, activated, version)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, user.Version}
+
+	err := s.db.QueryRow(ctx, query, args...).Scan(&user.ID, &user.CreatedAt)
+	return postgresError(err)
+}
+
+func (s postgresUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, version
+		FROM users
+		WHERE email = $1`
+
+	return s.scanUser(s.db.QueryRow(ctx, query, email))
+}
+
+func (s postgresUserStore) GetForToken(ctx context.Context, scope string, hash []byte, now time.Time) (*User, error) {
+	query := `
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+		FROM users
+		INNER JOIN tokens
+		ON users.id = tokens.user_id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > $3`
+
+	return s.scanUser(s.db.QueryRow(ctx, query, hash, scope, now))
+}
+
+func (s postgresUserStore) scanUser(row pgx.Row) (*User, error) {
+	var user User
+
+	err := row.Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+
+	return &user, nil
+}
+
+func (s postgresUserStore) Update(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users
+		SET name = $1, email = $2, password_hash = $3, activated = $4, version = $5
+		WHERE id = $6 AND version = $7
+		RETURNING version`
+
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, uuid.New(), user.ID, user.Version}
+
+	err := postgresError(s.db.QueryRow(ctx, query, args...).Scan(&user.Version))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"mauk14.library/internal/validator"
+	"strings"
+	"unicode"
+)
+
+// TextSearch is a parsed full-text query over the title, author and genres
+// of a book. A book matches when it contains every term, a word starting
+// with every prefix, and every phrase as consecutive words.
+type TextSearch struct {
+	Terms    []string
+	Prefixes []string
+	Phrases  [][]string
+}
+
+// ParseTextSearch parses the q parameter of a book listing. Words are
+// matched case-insensitively, "double quotes" group a phrase and a trailing
+// * makes a word a prefix.
+func ParseTextSearch(q string) *TextSearch {
+	search := &TextSearch{}
+
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			if words := searchTokens(part); len(words) == 1 {
+				search.Terms = append(search.Terms, words[0])
+			} else if len(words) > 1 {
+				search.Phrases = append(search.Phrases, words)
+			}
+			continue
+		}
+
+		for _, field := range strings.Fields(part) {
+			words := searchTokens(field)
+			if len(words) == 0 {
+				continue
+			}
+			if strings.HasSuffix(field, "*") {
+				last := len(words) - 1
+				search.Terms = append(search.Terms, words[:last]...)
+				search.Prefixes = append(search.Prefixes, words[last])
+				continue
+			}
+			search.Terms = append(search.Terms, words...)
+		}
+	}
+
+	return search
+}
+
+func (s *TextSearch) items() int {
+	return len(s.Terms) + len(s.Prefixes) + len(s.Phrases)
+}
+
+func ValidateTextSearch(v *validator.Validator, q string, search *TextSearch) {
+	v.Check(len(q) <= 200, "q", "must not be more than 200 bytes long")
+	v.Check(search.items() > 0, "q", "must contain at least one word")
+	v.Check(search.items() <= 10, "q", "must not contain more than 10 words or phrases")
+}
+
+// searchTokens splits s into lower-cased words, the way the search indexes
+// of every backend see text.
+func searchTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTextSearch(t *testing.T) {
+	tests := []struct {
+		name         string
+		q            string
+		want         *TextSearch
+		wantPostgres string
+		wantMongo    string
+	}{
+		{
+			name:         "terms",
+			q:            "Dune  HERBERT",
+			want:         &TextSearch{Terms: []string{"dune", "herbert"}},
+			wantPostgres: "dune & herbert",
+			wantMongo:    `"dune" "herbert"`,
+		},
+		{
+			name:         "phrase",
+			q:            `"Frank Herbert" dune`,
+			want:         &TextSearch{Terms: []string{"dune"}, Phrases: [][]string{{"frank", "herbert"}}},
+			wantPostgres: "dune & (frank <-> herbert)",
+			wantMongo:    `"dune" "frank herbert"`,
+		},
+		{
+			name:         "one-word phrase",
+			q:            `"Dune"`,
+			want:         &TextSearch{Terms: []string{"dune"}},
+			wantPostgres: "dune",
+			wantMongo:    `"dune"`,
+		},
+		{
+			name:         "prefix",
+			q:            "dun*",
+			want:         &TextSearch{Prefixes: []string{"dun"}},
+			wantPostgres: "dun:*",
+			wantMongo:    "",
+		},
+		{
+			name:         "prefix of a split word",
+			q:            "sci-fi*",
+			want:         &TextSearch{Terms: []string{"sci"}, Prefixes: []string{"fi"}},
+			wantPostgres: "sci & fi:*",
+			wantMongo:    `"sci"`,
+		},
+		{
+			name:         "operators are not words",
+			q:            `dune & !messiah | "" :*`,
+			want:         &TextSearch{Terms: []string{"dune", "messiah"}},
+			wantPostgres: "dune & messiah",
+			wantMongo:    `"dune" "messiah"`,
+		},
+		{
+			name: "no words",
+			q:    "*** !!",
+			want: &TextSearch{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseTextSearch(tt.q)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v; want %#v", got, tt.want)
+			}
+
+			if s := postgresTextSearch(got); s != tt.wantPostgres {
+				t.Errorf("got tsquery %q; want %q", s, tt.wantPostgres)
+			}
+			if s := mongoTextSearch(got); s != tt.wantMongo {
+				t.Errorf("got $text search %q; want %q", s, tt.wantMongo)
+			}
+		})
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	ctx := context.Background()
+	models := NewModels(NewMemory(), time.Second)
+
+	books := []*Book{
+		{Title: "The Hobbit", Author: "J. R. R. Tolkien", Year: 1937, Size: 310, Genres: []string{"fantasy"}},
+		{Title: "Tolkien: A Biography", Author: "Humphrey Carpenter", Year: 1977, Size: 287, Genres: []string{"biography"}},
+		{Title: "Dune", Author: "Frank Herbert", Year: 1965, Size: 412, Genres: []string{"science fiction"}},
+		{Title: "Reading Tolkien", Author: "The Tolkien Society", Year: 2001, Size: 120, Genres: []string{"criticism", "tolkien"}},
+	}
+	for _, book := range books {
+		if err := models.Books.Insert(ctx, book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Matches count for more in the title than in the author, and for more
+	// in the author than in the genres; books matching in several fields
+	// rank above books matching in one.
+	tests := []struct {
+		name    string
+		q       string
+		wantIDs []int64
+	}{
+		{"term", "tolkien", []int64{4, 2, 1}},
+		{"prefix", "tolk*", []int64{4, 2, 1}},
+		{"title and genres", "biography", []int64{2}},
+		{"all terms", "tolkien hobbit", []int64{1}},
+		{"phrase", `"frank herbert"`, []int64{3}},
+		{"phrase out of order", `"herbert frank"`, []int64{}},
+		{"no match", "silmarillion", []int64{}},
+	}
+
+	filters := Filters{Page: 1, PageSize: 10, Sort: "relevance", SortSafelist: []string{"relevance"}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := models.Books.GetAll(ctx, BookFilter{Search: ParseTextSearch(tt.q)}, filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := make([]int64, len(got))
+			for i, book := range got {
+				ids[i] = book.ID
+				if book.Score <= 0 {
+					t.Errorf("book %d: got score %v; want a positive score", book.ID, book.Score)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("got books %v; want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
@@ -11,9 +11,14 @@ var ErrInvalidSizeFormat = errors.New("invalid size format")
 
 type Size int32
 
+// String renders the size the way it appears in JSON, e.g. "320 pages".
+func (r Size) String() string {
+	return fmt.Sprintf("%d pages", r)
+}
+
 func (r Size) MarshalJSON() ([]byte, error) {
 
-	jsonValue := fmt.Sprintf("%d pages", r)
+	jsonValue := r.String()
 
 	quotedJSONValue := strconv.Quote(jsonValue)
 
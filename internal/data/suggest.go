@@ -0,0 +1,35 @@
+package data
+
+import (
+	"context"
+	"mauk14.library/internal/validator"
+)
+
+// SuggestSafelist lists the book fields that can be autocompleted.
+var SuggestSafelist = []string{"title", "author", "genres"}
+
+// Suggestion is a distinct field value starting with the requested prefix,
+// with the number of books carrying it.
+type Suggestion struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Suggest returns up to limit distinct values of field that start with
+// prefix, ignoring case, the most common first.
+func (m *BookModel) Suggest(ctx context.Context, field string, prefix string, limit int) ([]Suggestion, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.Suggest(ctx, field, prefix, limit)
+}
+
+func ValidateSuggest(v *validator.Validator, field string, prefix string, limit int) {
+	v.Check(validator.PermittedValue(field, SuggestSafelist...), "field", "invalid field value")
+
+	v.Check(prefix != "", "prefix", "must be provided")
+	v.Check(len(prefix) <= 100, "prefix", "must not be more than 100 bytes long")
+
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+}
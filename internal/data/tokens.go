@@ -27,10 +27,10 @@ func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
 	v.Check(len(tokenPlaintext) == 26, "token", "must be 26 bytes long")
 }
 
-func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
+func generateToken(userID int64, expiry time.Time, scope string) (*Token, error) {
 	token := &Token{
 		UserID: userID,
-		Expiry: time.Now().Add(ttl),
+		Expiry: expiry,
 		Scope:  scope,
 	}
 
@@ -50,28 +50,39 @@ func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 }
 
 type TokenModel struct {
-	DB DB
+	Store   TokenStore
+	Timeout time.Duration
+	Now     func() time.Time
 }
 
-func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
-	token, err := generateToken(userID, ttl, scope)
+func (m TokenModel) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, m.Now().Add(ttl), scope)
 	if err != nil {
 		return nil, err
 	}
-	err = m.Insert(token)
+	err = m.Insert(ctx, token)
 	return token, err
 }
 
-func (m TokenModel) Insert(token *Token) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m TokenModel) Insert(ctx context.Context, token *Token) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	return m.DB.Insert(ctx, "", "tokens", token)
+	return m.Store.Insert(ctx, token)
 }
 
-func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m TokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	return m.DB.Delete(ctx, "", userID, "tokens", scope)
+	return m.Store.DeleteAllForUser(ctx, scope, userID)
+}
+
+// DeleteExpired removes every token whose expiry is not after the current
+// time and reports how many were deleted.
+func (m TokenModel) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.Store.DeleteExpired(ctx, m.Now())
 }
@@ -0,0 +1,106 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for Models.WithClock.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newClockModels(t *testing.T, c *clock) (Models, *User) {
+	t.Helper()
+
+	models := NewModels(NewMemory(), time.Second).WithClock(c.Now)
+
+	user := &User{Name: "Test User", Email: "alice@example.com", Activated: true}
+	if err := user.Password.Set("pa55word1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Users.Insert(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	return models, user
+}
+
+func TestGetForTokenExpiry(t *testing.T) {
+	c := &clock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	models, user := newClockModels(t, c)
+
+	token, err := models.Tokens.New(context.Background(), user.ID, time.Hour, ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		valid bool
+	}{
+		{"at creation", c.now, true},
+		{"just before expiry", token.Expiry.Add(-time.Nanosecond), true},
+		{"at expiry", token.Expiry, false},
+		{"after expiry", token.Expiry.Add(time.Nanosecond), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.now = tt.now
+
+			got, err := models.Users.GetForToken(context.Background(), ScopeAuthentication, token.Plaintext)
+			switch {
+			case tt.valid && err != nil:
+				t.Fatalf("got error %v; want the user", err)
+			case tt.valid && got.ID != user.ID:
+				t.Fatalf("got user %d; want %d", got.ID, user.ID)
+			case !tt.valid && !errors.Is(err, ErrRecordNotFound):
+				t.Fatalf("got error %v; want ErrRecordNotFound", err)
+			}
+		})
+	}
+}
+
+func TestDeleteExpiredTokens(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := &clock{now: start}
+	models, user := newClockModels(t, c)
+
+	ctx := context.Background()
+
+	short, err := models.Tokens.New(ctx, user.ID, time.Hour, ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, err := models.Tokens.New(ctx, user.ID, 3*time.Hour, ScopeActivation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.now = short.Expiry.Add(-time.Nanosecond)
+	if n, err := models.Tokens.DeleteExpired(ctx); err != nil || n != 0 {
+		t.Fatalf("before expiry: got %d, %v; want 0, nil", n, err)
+	}
+
+	c.now = short.Expiry
+	if n, err := models.Tokens.DeleteExpired(ctx); err != nil || n != 1 {
+		t.Fatalf("at expiry: got %d, %v; want 1, nil", n, err)
+	}
+
+	// The expired token is gone even if the clock is turned back.
+	c.now = start
+	if _, err := models.Users.GetForToken(ctx, ScopeAuthentication, short.Plaintext); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("expired token: got error %v; want ErrRecordNotFound", err)
+	}
+	if _, err := models.Users.GetForToken(ctx, ScopeActivation, long.Plaintext); err != nil {
+		t.Errorf("live token: got error %v; want nil", err)
+	}
+}
@@ -3,10 +3,8 @@ package data
 import (
 	"context"
 	"crypto/sha256"
-	"database/sql"
 	"errors"
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 	"mauk14.library/internal/validator"
 	"time"
@@ -86,100 +84,43 @@ func ValidateUser(v *validator.Validator, user *User) {
 }
 
 type UserModel struct {
-	DB DB
+	Store   UserStore
+	Timeout time.Duration
+	Now     func() time.Time
 }
 
-func (m UserModel) Insert(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m UserModel) Insert(ctx context.Context, user *User) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	id, err := m.DB.GetLastId(ctx, "", "users")
-
-	if err != nil {
-		return err
-	}
-
-	user.ID = id + 1
 	user.Version = uuid.New()
 	user.CreatedAt = time.Now()
 
-	err = m.DB.Insert(ctx, "", "users", user)
-
-	if err != nil {
-		switch {
-		case err.Error() == `write exception: write errors: [E11000 duplicate key error collection: Library.users index: email_1 dup key: { email: "`+user.Email+`" }]`:
-			return ErrDuplicateEmail
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrEditConflict
-		default:
-			return err
-		}
-	}
-
-	return nil
+	return m.Store.Insert(ctx, user)
 
 }
 
-func (m UserModel) GetByEmail(email string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	var user User
-	var result interface{}
-
-	result, err := m.DB.Get(ctx, "", email, "users", "")
-	if err != nil {
-		switch {
-		case errors.Is(err, mongo.ErrNoDocuments):
-			return nil, ErrRecordNotFound
-		default:
-			return nil, err
-		}
-
-	}
-
-	user = result.(User)
-
-	return &user, nil
+	return m.Store.GetByEmail(ctx, email)
 }
 
-func (m *UserModel) Update(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *UserModel) Update(ctx context.Context, user *User) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	err := m.DB.Update(ctx, "", user)
-
-	if err != nil {
-		switch {
-		case errors.Is(err, mongo.ErrNoDocuments):
-			return ErrEditConflict
-		default:
-			return err
-		}
-	}
-
-	return nil
+	return m.Store.Update(ctx, user)
 }
 
-func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+// GetForToken returns the owner of a token with the given scope. Tokens
+// whose expiry is not after the current time are treated as not found.
+func (m UserModel) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
-	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	result, err := m.DB.Get(ctx, "", tokenHash, "tokens", tokenScope)
-	if err != nil {
-		switch {
-		case errors.Is(err, mongo.ErrNoDocuments):
-			return nil, ErrRecordNotFound
-		default:
-			return nil, err
-		}
-
-	}
-
-	user = result.(User)
-
-	return &user, nil
+	return m.Store.GetForToken(ctx, tokenScope, tokenHash[:], m.Now())
 }
@@ -0,0 +1,92 @@
+package validator
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NormalizeISBN strips the hyphens and spaces ISBNs are usually printed with
+// and upper-cases an X check digit, so equal ISBNs compare equal.
+func NormalizeISBN(s string) string {
+	s = strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s))
+	return strings.ToUpper(s)
+}
+
+// ValidISBN10 reports whether s is a normalized ISBN-10 with a correct check
+// digit.
+func ValidISBN10(s string) bool {
+	if len(s) != 10 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 10; i++ {
+		var digit int
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			digit = int(s[i] - '0')
+		case s[i] == 'X' && i == 9:
+			digit = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * digit
+	}
+
+	return sum%11 == 0
+}
+
+// ValidISBN13 reports whether s is a normalized ISBN-13 with a correct check
+// digit and one of the 978 or 979 prefixes.
+func ValidISBN13(s string) bool {
+	if len(s) != 13 || !strings.HasPrefix(s, "978") && !strings.HasPrefix(s, "979") {
+		return false
+	}
+
+	for i := 0; i < 13; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return isbn13CheckDigit(s[:12]) == s[12]
+}
+
+func isbn13CheckDigit(s string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(s[i]-'0')
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ISBN10To13 converts a valid ISBN-10 to its ISBN-13 form.
+func ISBN10To13(s string) string {
+	s = "978" + s[:9]
+	return s + string(isbn13CheckDigit(s))
+}
+
+// ISBN13To10 converts a valid ISBN-13 to its ISBN-10 form. Only ISBNs with the
+// 978 prefix have one, so the second result is false for the others.
+func ISBN13To10(s string) (string, bool) {
+	if !strings.HasPrefix(s, "978") {
+		return "", false
+	}
+
+	s = s[3:12]
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(s[i]-'0')
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return s + "X", true
+	}
+	return s + strconv.Itoa(check), true
+}
@@ -0,0 +1,2 @@
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_size_check;
+ALTER TABLE books DROP CONSTRAINT IF EXISTS genres_length_check;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS books_search_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS search;
+DROP FUNCTION IF EXISTS books_search_vector(text, text, text[]);
@@ -0,0 +1,13 @@
+CREATE OR REPLACE FUNCTION books_search_vector(title text, author text, genres text[])
+RETURNS tsvector
+LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$
+    SELECT setweight(to_tsvector('simple', title), 'A')
+        || setweight(to_tsvector('simple', author), 'B')
+        || setweight(to_tsvector('simple', array_to_string(genres, ' ')), 'C')
+$$;
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search tsvector
+    GENERATED ALWAYS AS (books_search_vector(title, author, genres)) STORED;
+
+CREATE INDEX IF NOT EXISTS books_search_idx ON books USING GIN (search);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS books_title_prefix_idx;
+DROP INDEX IF EXISTS books_author_prefix_idx;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS books_title_prefix_idx ON books (lower(title) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS books_author_prefix_idx ON books (lower(author) text_pattern_ops);
@@ -0,0 +1 @@
+DROP COLLATION IF EXISTS books_ci;
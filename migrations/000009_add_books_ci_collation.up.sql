@@ -0,0 +1 @@
+CREATE COLLATION IF NOT EXISTS books_ci (provider = icu, locale = 'und-u-ks-level2', deterministic = false);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS books_isbn_13_key;
+ALTER TABLE books DROP COLUMN IF EXISTS isbn_13;
+ALTER TABLE books DROP COLUMN IF EXISTS isbn_10;
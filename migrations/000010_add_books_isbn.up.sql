@@ -0,0 +1,3 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn_10 text NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn_13 text NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS books_isbn_13_key ON books (isbn_13) WHERE isbn_13 <> '';
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS authors (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    version uuid NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS books_authors (
+    book_id bigint NOT NULL REFERENCES books ON DELETE CASCADE,
+    author_id bigint NOT NULL REFERENCES authors ON DELETE CASCADE,
+    role text NOT NULL CHECK (role IN ('author', 'editor', 'translator', 'illustrator')),
+    position integer NOT NULL,
+    PRIMARY KEY (book_id, author_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS books_authors_author_id_idx ON books_authors (author_id);
+
+INSERT INTO authors (name, version)
+SELECT name, gen_random_uuid()
+FROM (SELECT DISTINCT author AS name FROM books) AS names
+ORDER BY name;
+
+INSERT INTO books_authors (book_id, author_id, role, position)
+SELECT books.id, authors.id, 'author', 0
+FROM books
+INNER JOIN authors
+ON authors.name = books.author;
@@ -0,0 +1,7 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS work_id bigint;
+UPDATE books SET work_id = id WHERE work_id IS NULL;
+ALTER TABLE books ALTER COLUMN work_id SET NOT NULL;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS format text NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS duration integer NOT NULL DEFAULT 0;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS publisher text NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS books_work_id_idx ON books (work_id);
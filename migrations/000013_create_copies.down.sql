@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE code = 'copies:write';
+DROP TABLE IF EXISTS copies;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS copies (
+    id bigserial PRIMARY KEY,
+    book_id bigint NOT NULL REFERENCES books ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    barcode text NOT NULL,
+    branch text NOT NULL,
+    shelf text NOT NULL DEFAULT '',
+    condition text NOT NULL CHECK (condition IN ('new', 'good', 'fair', 'poor', 'damaged')),
+    status text NOT NULL CHECK (status IN ('available', 'on_loan', 'lost', 'in_repair')),
+    version uuid NOT NULL,
+    CONSTRAINT copies_barcode_key UNIQUE (barcode)
+);
+
+CREATE INDEX IF NOT EXISTS copies_book_id_idx ON copies (book_id);
+
+INSERT INTO permissions (code)
+VALUES ('copies:write')
+ON CONFLICT DO NOTHING;